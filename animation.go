@@ -0,0 +1,158 @@
+package main
+
+import (
+	"time"
+
+	"lazyhacker.dev/wa-tor/internal/wator"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// maxAnimationTicks is the longest the move from one chronon to the next is
+// animated.  At slower speeds the creatures rest for the rest of the chronon.
+const maxAnimationTicks = 64
+
+// animation is the change of the world from the previous chronon to the
+// shown one as it is drawn.  Only the latest chronon is ever animated so
+// the screen never lags behind the world: a chronon that arrives before the
+// animation of the one before finished replaces it.
+type animation struct {
+	changes  []wator.Delta    // Changes leading to the state.
+	state    wator.WorldState // Creatures at the end of the animation.
+	start    time.Time
+	duration time.Duration
+}
+
+// progress returns how far the animation is at the time, from 0 at the
+// start to 1 when it finished.
+func (a *animation) progress(now time.Time) float64 {
+
+	if a.duration <= 0 {
+		return 1
+	}
+	return min(max(float64(now.Sub(a.start))/float64(a.duration), 0), 1)
+}
+
+// animate starts animating the changes that lead to the state.  The
+// animation is shortened to fit in the time of a chronon at the current
+// speed.
+func (g *Game) animate(changes []wator.Delta, state wator.WorldState) {
+
+	ticks := min(g.tpsPerChronon, maxAnimationTicks)
+	g.anim = animation{
+		changes:  changes,
+		state:    state,
+		start:    time.Now(),
+		duration: time.Duration(ticks) * time.Second / time.Duration(ebiten.TPS()),
+	}
+}
+
+// show draws the state without animation.
+func (g *Game) show(state wator.WorldState) {
+	g.anim = animation{state: state}
+}
+
+// eatProgress is how far through the move of a shark that eats its mouth
+// reaches the fish.  The fish dies from then on and is gone when the shark
+// arrives.
+const eatProgress = 0.5
+
+// deathSprite returns the sprite of the death animation that is f of the way
+// through, from 0 to 1.
+func deathSprite(f float64) int {
+	return DeathStartIdx + min(max(int(f*DeathFrames), 0), DeathFrames-1)
+}
+
+// InterpolateFrame returns the tiles of the world part way through the
+// changes leading to the state.  progress is 0 at the previous chronon and 1
+// at the chronon of the state, where every creature is drawn where the state
+// has it.  Until then moving creatures are drawn between the two
+// positions, sharks that eat open their mouths, new born creatures appear
+// where their parent was and sharks that starved die where they were.  Fish
+// that were eaten stay under the shark until it reaches them and then die.
+// A creature moving across an edge of the world is at the edge it wraps
+// around to, so the camera draws it sliding out of one side and into the
+// other.
+func (g *Game) InterpolateFrame(changes []wator.Delta, state wator.WorldState, progress float64) []Frame {
+
+	progress = min(max(progress, 0), 1)
+	steps := g.AnimationSteps()
+	step := min(int(progress*float64(steps)), steps-1)
+	offset := progress * TileSize
+
+	// Sprite of the fish being eaten.
+	eaten := 0
+	if progress >= eatProgress {
+		eaten = deathSprite((progress - eatProgress) / (1 - eatProgress))
+	}
+
+	var gone, moving []Frame
+	arrived := make(map[int]int) // Index in moving of the creature moving to a tile.
+	ate := make(map[int]bool)    // Tiles of the sharks that eat during their move.
+	born := make(map[int]bool)   // Tiles of the new born creatures.
+	if progress == 1 {
+		// Every creature is where the state has it.
+		changes = nil
+	}
+	for _, d := range changes {
+		x, y := g.TileCoordinate(d.From)
+		sprite := step
+		switch d.Action {
+		case wator.MOVE_EAST:
+			x += offset
+		case wator.MOVE_WEST:
+			x -= offset
+			sprite += steps
+		case wator.MOVE_NORTH:
+			y -= offset
+		case wator.MOVE_SOUTH:
+			y += offset
+		case wator.DEATH:
+			gone = append(gone, Frame{sprite: deathSprite(progress), tileType: d.Object, x: x, y: y})
+			continue
+		case wator.ATE:
+			ate[d.From] = true
+			// A fish that moved before it was eaten is already drawn
+			// moving there.
+			if i, ok := arrived[d.To]; ok {
+				if progress >= eatProgress {
+					moving[i].sprite = eaten
+				}
+			} else {
+				x, y := g.TileCoordinate(d.To)
+				gone = append(gone, Frame{sprite: eaten, tileType: wator.FISH, x: x, y: y})
+			}
+			continue
+		case wator.BIRTH:
+			born[d.From] = true
+			continue
+		default:
+			continue
+		}
+		if ate[d.From] {
+			sprite += 2 * steps
+			delete(ate, d.From)
+		}
+
+		// A shark moving where a fish moved earlier ate it and is drawn
+		// on top of it.
+		x, y = g.wrapPosition(x, y)
+		arrived[d.To] = len(moving)
+		moving = append(moving, Frame{sprite: sprite, tileType: d.Object, x: x, y: y})
+	}
+
+	frame := gone
+	for i, t := range state {
+		if _, ok := arrived[i]; ok || t == wator.NONE {
+			continue
+		}
+		x, y := g.TileCoordinate(i)
+		f := Frame{tileType: t, x: x, y: y}
+		if born[i] {
+			f.born, f.grow = true, progress
+		}
+		frame = append(frame, f)
+	}
+
+	return append(frame, moving...)
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	maxZoom      = 4.0 // screen pixels per world pixel when zoomed in the most
+	zoomStep     = 1.1 // zoom change of a notch of the mouse wheel
+	panSpeed     = 8   // screen pixels the keys pan the camera every tick
+	minGridPitch = 8   // tiles smaller than this on the screen have no grid
+)
+
+// camera is the part of the world on the screen.  The world is a torus so
+// the camera pans past the edges onto the other side, and it repeats when
+// zoomed out far enough.
+type camera struct {
+	x, y     float64 // world pixel at the top left of the screen
+	zoom     float64 // screen pixels per world pixel
+	fit      bool    // the whole world is fitted to the screen until the camera is moved
+	dragging bool    // the camera is dragged with the mouse
+	lastX    int     // cursor position during the last tick of the drag
+	lastY    int
+}
+
+// worldSize returns the size of the world in world pixels.
+func (g *Game) worldSize() (float64, float64) {
+	return float64(g.world.Width * TileSize), float64(g.world.Height * TileSize)
+}
+
+// fitCamera zooms to show the whole world centered on the screen.
+func (g *Game) fitCamera() {
+
+	ww, wh := g.worldSize()
+	if ww == 0 || wh == 0 || g.screenW == 0 || g.screenH == 0 {
+		return
+	}
+	g.cam.zoom = min(float64(g.screenW)/ww, float64(g.screenH)/wh)
+	g.cam.x = (ww - float64(g.screenW)/g.cam.zoom) / 2
+	g.cam.y = (wh - float64(g.screenH)/g.cam.zoom) / 2
+	g.cam.fit = true
+}
+
+// minZoom returns the zoom showing the whole world.
+func (g *Game) minZoom() float64 {
+
+	ww, wh := g.worldSize()
+	if ww == 0 || wh == 0 || g.screenW == 0 || g.screenH == 0 {
+		return 1
+	}
+	return min(float64(g.screenW)/ww, float64(g.screenH)/wh, 1)
+}
+
+// zoomAt changes the zoom by the factor keeping the world under the screen
+// position in place.
+func (g *Game) zoomAt(sx, sy int, factor float64) {
+
+	zoom := min(max(g.cam.zoom*factor, g.minZoom()), maxZoom)
+	g.cam.x += float64(sx)/g.cam.zoom - float64(sx)/zoom
+	g.cam.y += float64(sy)/g.cam.zoom - float64(sy)/zoom
+	g.cam.zoom = zoom
+	g.cam.fit = false
+	g.wrapCamera()
+}
+
+// pan moves the world on the screen by dx and dy screen pixels.
+func (g *Game) pan(dx, dy float64) {
+
+	g.cam.x -= dx / g.cam.zoom
+	g.cam.y -= dy / g.cam.zoom
+	g.cam.fit = false
+	g.wrapCamera()
+}
+
+// wrapCamera keeps the camera position within the world.  Being a torus, the
+// view is the same.
+func (g *Game) wrapCamera() {
+
+	ww, wh := g.worldSize()
+	if ww > 0 && wh > 0 {
+		g.cam.x = wrap(g.cam.x, ww)
+		g.cam.y = wrap(g.cam.y, wh)
+	}
+}
+
+// wrap returns v within 0 and size, like the positions of a torus.
+func wrap(v, size float64) float64 {
+
+	v = math.Mod(v, size)
+	if v < 0 {
+		v += size
+	}
+	return v
+}
+
+// wrapPosition returns the world pixel within the world, which is the same
+// place of the torus.
+func (g *Game) wrapPosition(x, y float64) (float64, float64) {
+
+	ww, wh := g.worldSize()
+	if ww > 0 {
+		x = wrap(x, ww)
+	}
+	if wh > 0 {
+		y = wrap(y, wh)
+	}
+	return x, y
+}
+
+// ScreenToWorld converts a position on the screen to the world pixel shown
+// there, within the world.
+func (g *Game) ScreenToWorld(sx, sy int) (float64, float64) {
+
+	ww, wh := g.worldSize()
+	return wrap(g.cam.x+float64(sx)/g.cam.zoom, ww), wrap(g.cam.y+float64(sy)/g.cam.zoom, wh)
+}
+
+// cursorTile returns the tile under the mouse cursor.
+func (g *Game) cursorTile() (int, bool) {
+
+	x, y := ebiten.CursorPosition()
+	wx, wy := g.ScreenToWorld(x, y)
+	return g.TileAt(int(wx), int(wy))
+}
+
+// onScreen calls draw with the screen position of every copy of the w by h
+// area at the world pixel (x, y) that is visible.  Nothing is drawn for an
+// area off the screen.
+func (g *Game) onScreen(x, y, w, h float64, draw func(sx, sy float64)) {
+
+	ww, wh := g.worldSize()
+	if ww == 0 || wh == 0 || g.cam.zoom == 0 {
+		return
+	}
+	viewW, viewH := float64(g.screenW)/g.cam.zoom, float64(g.screenH)/g.cam.zoom
+
+	// Copies of the area one world apart that overlap the view.
+	for ky := math.Ceil((g.cam.y - y - h) / wh); y+ky*wh < g.cam.y+viewH; ky++ {
+		for kx := math.Ceil((g.cam.x - x - w) / ww); x+kx*ww < g.cam.x+viewW; kx++ {
+			draw((x+kx*ww-g.cam.x)*g.cam.zoom, (y+ky*wh-g.cam.y)*g.cam.zoom)
+		}
+	}
+}
+
+// updateCamera zooms with the mouse wheel, pans with the W/A/S/D keys and by
+// dragging, and fits the world to the screen with F.  keys is false when
+// the keys are used for something else.  The left mouse button only drags
+// the camera if it is free.
+func (g *Game) updateCamera(keys, leftFree bool) {
+
+	x, y := ebiten.CursorPosition()
+	if _, dy := ebiten.Wheel(); dy != 0 {
+		g.zoomAt(x, y, math.Pow(zoomStep, dy))
+	}
+
+	if keys {
+		if inpututil.IsKeyJustPressed(ebiten.KeyF) {
+			g.fitCamera()
+		}
+		var dx, dy float64
+		if ebiten.IsKeyPressed(ebiten.KeyA) {
+			dx += panSpeed
+		}
+		if ebiten.IsKeyPressed(ebiten.KeyD) {
+			dx -= panSpeed
+		}
+		if ebiten.IsKeyPressed(ebiten.KeyW) {
+			dy += panSpeed
+		}
+		if ebiten.IsKeyPressed(ebiten.KeyS) && !ebiten.IsKeyPressed(ebiten.KeyControl) {
+			dy -= panSpeed
+		}
+		if dx != 0 || dy != 0 {
+			g.pan(dx, dy)
+		}
+	}
+
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonMiddle) ||
+		(leftFree && inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft)) {
+		g.cam.dragging = true
+		g.cam.lastX, g.cam.lastY = x, y
+	}
+	if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonMiddle) && !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+		g.cam.dragging = false
+	}
+	if g.cam.dragging && (x != g.cam.lastX || y != g.cam.lastY) {
+		g.pan(float64(x-g.cam.lastX), float64(y-g.cam.lastY))
+		g.cam.lastX, g.cam.lastY = x, y
+	}
+}
+
+// drawGrid draws the lines between the tiles on the screen.  The edges of
+// the world are drawn in another colour to show where it wraps around.
+func (g *Game) drawGrid(screen *ebiten.Image) {
+
+	if TileSize*g.cam.zoom < minGridPitch {
+		return
+	}
+	ww, wh := g.worldSize()
+	w, h := float64(g.screenW), float64(g.screenH)
+	edge := color.RGBA{255, 240, 160, 255}
+
+	for x := math.Ceil(g.cam.x/TileSize) * TileSize; x < g.cam.x+w/g.cam.zoom; x += TileSize {
+		clr := color.Color(color.White)
+		if wrap(x, ww) == 0 {
+			clr = edge
+		}
+		sx := (x - g.cam.x) * g.cam.zoom
+		ebitenutil.DrawLine(screen, sx, 0, sx, h, clr)
+	}
+	for y := math.Ceil(g.cam.y/TileSize) * TileSize; y < g.cam.y+h/g.cam.zoom; y += TileSize {
+		clr := color.Color(color.White)
+		if wrap(y, wh) == 0 {
+			clr = edge
+		}
+		sy := (y - g.cam.y) * g.cam.zoom
+		ebitenutil.DrawLine(screen, 0, sy, w, sy, clr)
+	}
+}
+
+// mouseFree reports whether the left mouse button is not used by anything
+// else on the screen and can drag the camera.
+func (g *Game) mouseFree() bool {
+
+	x, y := ebiten.CursorPosition()
+	switch {
+	case g.editor != nil:
+		return false
+	case g.panelOpen && image.Pt(x, y).In(panelRect()):
+		return false
+	case g.player != nil && y >= g.screenH-ScrubberHeight:
+		return false
+	}
+	return true
+}
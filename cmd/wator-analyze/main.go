@@ -0,0 +1,91 @@
+// Wator-analyze reports the predator-prey oscillations of populations
+// recorded by wator-sim: the dominant period of the fish and sharks from the
+// autocorrelation and the spectrum, the amplitude, how far the sharks lag
+// behind the fish and whether the cycles are damped or growing.
+//
+// # Usage:
+//
+//	wator-sim -steps 10000 -seed 1 > run.csv
+//	wator-analyze -skip 1000 run.csv
+//
+// Without a file the statistics are read from stdin.  Files holding several
+// runs are analysed run by run.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"lazyhacker.dev/wa-tor/internal/analysis"
+	"lazyhacker.dev/wa-tor/internal/wator"
+)
+
+// run analyses the file named in the arguments, or stdin, and writes the
+// reports to stdout.
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+
+	fs := flag.NewFlagSet("wator-analyze", flag.ContinueOnError)
+	skip := fs.Uint("skip", 0, "ignore the chronons before this one, e.g. the initial transient")
+	asJSON := fs.Bool("json", false, "write the reports as JSON Lines")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	in := stdin
+	if fs.NArg() > 1 {
+		return fmt.Errorf("Expected at most one file to analyse.")
+	}
+	if fs.NArg() == 1 {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	runs, err := wator.ReadCSV(in)
+	if err != nil {
+		return fmt.Errorf("Unable to read statistics. %v", err)
+	}
+
+	enc := json.NewEncoder(stdout)
+	for i, rec := range runs {
+		var fish, sharks []float64
+		for _, s := range rec.Samples {
+			if s.Chronon >= *skip {
+				fish = append(fish, float64(s.Fish))
+				sharks = append(sharks, float64(s.Sharks))
+			}
+		}
+		report := analysis.Analyze(fish, sharks, float64(rec.Every))
+
+		if *asJSON {
+			err = enc.Encode(struct {
+				Params wator.Params    `json:"params"`
+				Report analysis.Report `json:"report"`
+			}{rec.Params, report})
+		} else {
+			if i > 0 {
+				fmt.Fprintln(stdout)
+			}
+			fmt.Fprintf(stdout, "Run %d: %v\n", i+1, rec.Params)
+			err = report.Write(stdout)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"lazyhacker.dev/wa-tor/internal/analysis"
+	"lazyhacker.dev/wa-tor/internal/wator"
+)
+
+// cycles returns a recording of populations oscillating with a period of 100
+// chronons, sampled every 2 chronons, with the sharks 20 chronons behind.
+func cycles() *wator.Recorder {
+	rec := &wator.Recorder{Params: wator.Params{Width: 10, Height: 10, Seed: 4}, Every: 2}
+	for c := 0; c < 1000; c += 2 {
+		fish := 300 + 100*math.Sin(2*math.Pi*float64(c)/100)
+		sharks := 50 + 10*math.Sin(2*math.Pi*float64(c-20)/100)
+		// A transient that -skip removes.
+		if c < 100 {
+			fish = 1000
+		}
+		rec.Samples = append(rec.Samples, wator.Stats{Chronon: uint(c), Fish: int(fish), Sharks: int(sharks)})
+	}
+	return rec
+}
+
+func TestRun(t *testing.T) {
+	var csv bytes.Buffer
+	if err := cycles().WriteCSV(&csv); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "run.csv")
+	if err := os.WriteFile(path, csv.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := run([]string{"-skip", "100", "-json", path}, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Params wator.Params
+		Report analysis.Report
+	}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("Unable to decode %q: %v", out.String(), err)
+	}
+	if got.Params.Seed != 4 {
+		t.Errorf("Expected the params of the run, got %+v", got.Params)
+	}
+	if got.Report.Fish.ACFPeriod != 100 || got.Report.Lag != 20 {
+		t.Errorf("Expected a period of 100 and lag of 20, got %d and %v", got.Report.Fish.ACFPeriod, got.Report.Lag)
+	}
+}
+
+func TestRunStdin(t *testing.T) {
+	var in bytes.Buffer
+	for i := 0; i < 2; i++ {
+		if err := cycles().WriteCSV(&in); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var out bytes.Buffer
+	if err := run([]string{"-skip", "100"}, &in, &out); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 2; i++ {
+		if want := fmt.Sprintf("Run %d: width=10", i); !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in:\n%s", want, out.String())
+		}
+	}
+	if !strings.Contains(out.String(), "period (ACF)  100 chronons") {
+		t.Errorf("Expected the period in the report:\n%s", out.String())
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	if err := run([]string{"a.csv", "b.csv"}, nil, &bytes.Buffer{}); err == nil {
+		t.Error("Expected an error for two files")
+	}
+	if err := run([]string{filepath.Join(t.TempDir(), "missing.csv")}, nil, &bytes.Buffer{}); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+	if err := run(nil, strings.NewReader("1,2\n"), &bytes.Buffer{}); err == nil {
+		t.Error("Expected an error for a malformed file")
+	}
+}
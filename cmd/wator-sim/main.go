@@ -0,0 +1,293 @@
+// Wator-sim runs the Wa-Tor simulation without a display as fast as possible
+// and writes the population statistics of every chronon to stdout or a file.
+// It is meant for running experiments on machines without a screen.
+//
+// A run ends after -steps chronons or earlier when one of the stop conditions
+// is met: the detectors listed in -stop-on (extinction, full, repeat,
+// stationary) or the expression in -stop, such as "sharks < 5 or chronon >
+// 10000".  With -restarts, new runs with a fresh seed follow and the
+// statistics of each run are written one after the other, each with its own
+// header.  Why each run ended is logged to stderr.
+//
+// -plot and -phase draw the population over time and the phase portrait of
+// each run as PNG or SVG, depending on the file extension.  With restarts the
+// run number is added to the file names.
+//
+// -spatial measures the clusters, pair correlations and Moran's I of the
+// creatures every -spatial-every chronons and writes them as CSV or, with the
+// cluster size distributions, as JSON Lines depending on the file extension.
+//
+// -replay writes the starting world and the changes of every chronon so the
+// run can be watched in the graphical version with -play.
+//
+// # Usage:
+//
+//	wator-sim -width 200 -height 200 -fish 8000 -sharks 2000 -steps 5000 -seed 1 > run.csv
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"lazyhacker.dev/wa-tor/internal/chart"
+	"lazyhacker.dev/wa-tor/internal/spatial"
+	"lazyhacker.dev/wa-tor/internal/wator"
+)
+
+// config holds the command-line settings of a run.
+type config struct {
+	fish, sharks  int
+	fsr, ssr      int
+	health        int
+	width, height int
+	steps         int
+	seed          int64
+	every         uint
+	format        string
+	out           string
+	stopOn        string
+	stop          string
+	restarts      int
+	plot          string
+	phase         string
+	spatial       string
+	spatialEvery  uint
+	rdfRadius     int
+	replay        string
+}
+
+// parseFlags reads the settings from the command-line arguments.  The world
+// flags are the same as the ones of the graphical version.
+func parseFlags(args []string) (*config, error) {
+
+	c := &config{}
+	fs := flag.NewFlagSet("wator-sim", flag.ContinueOnError)
+	fs.IntVar(&c.fish, "fish", 50, "Initial # of fish.")
+	fs.IntVar(&c.sharks, "sharks", 10, "Initial # of sharks.")
+	fs.IntVar(&c.fsr, "fish-spawn-rate", 15, "fish spawn rate")
+	fs.IntVar(&c.ssr, "shark-spawn-rate", 50, "shark spawn rate")
+	fs.IntVar(&c.health, "health", 20, "# of cycles shark can go with feeding before dying.")
+	fs.IntVar(&c.width, "width", 16, "number of tiles horizontally (cols)")
+	fs.IntVar(&c.height, "height", 12, "number of tiles verticals (rows)")
+	fs.IntVar(&c.steps, "steps", 1000, "number of chronons to run")
+	fs.Int64Var(&c.seed, "seed", 0, "seed for the random numbers, 0 picks one")
+	fs.UintVar(&c.every, "every", 1, "record the statistics of every Nth chronon")
+	fs.StringVar(&c.format, "format", "csv", "output format: csv or jsonl")
+	fs.StringVar(&c.out, "out", "", "file to write the statistics to instead of stdout")
+	fs.StringVar(&c.stopOn, "stop-on", "", "comma separated detectors ending a run: extinction, full, repeat, stationary")
+	fs.StringVar(&c.stop, "stop", "", `expression ending a run, e.g. "sharks < 5 or chronon > 10000"`)
+	fs.IntVar(&c.restarts, "restarts", 0, "number of runs with a fresh seed after the first one ends")
+	fs.StringVar(&c.plot, "plot", "", "file to draw the population over time to, .png or .svg")
+	fs.StringVar(&c.phase, "phase", "", "file to draw the phase portrait (sharks vs fish) to, .png or .svg")
+	fs.StringVar(&c.spatial, "spatial", "", "file to write the spatial pattern metrics to, .csv or .jsonl")
+	fs.UintVar(&c.spatialEvery, "spatial-every", 10, "measure the spatial patterns every Nth chronon")
+	fs.IntVar(&c.rdfRadius, "rdf-radius", 10, "largest radius of the pair correlation functions")
+	fs.StringVar(&c.replay, "replay", "", "file to write the replay of the run to for playback, compressed if it ends in .gz")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if c.format != "csv" && c.format != "jsonl" {
+		return nil, fmt.Errorf("Unknown format %q, expected csv or jsonl.", c.format)
+	}
+	if c.steps < 0 || c.restarts < 0 {
+		return nil, fmt.Errorf("Number of steps and restarts cannot be negative.")
+	}
+	if _, err := wator.ParseStopConditions(c.stopOn, c.stop); err != nil {
+		return nil, err
+	}
+	for _, name := range []string{c.plot, c.phase} {
+		if ext := strings.ToLower(filepath.Ext(name)); name != "" && ext != ".png" && ext != ".svg" {
+			return nil, fmt.Errorf("Unknown chart format %q, expected .png or .svg.", ext)
+		}
+	}
+	if ext := strings.ToLower(filepath.Ext(c.spatial)); c.spatial != "" && ext != ".csv" && ext != ".jsonl" {
+		return nil, fmt.Errorf("Unknown spatial metrics format %q, expected .csv or .jsonl.", ext)
+	}
+
+	return c, nil
+}
+
+// run executes the simulations described by the arguments and writes the
+// statistics to stdout unless an output file is given.
+func run(args []string, stdout io.Writer) error {
+
+	c, err := parseFlags(args)
+	if err != nil {
+		return err
+	}
+
+	if c.out == "" {
+		return runAll(c, stdout)
+	}
+	f, err := os.Create(c.out)
+	if err != nil {
+		return fmt.Errorf("Unable to create output file. %v", err)
+	}
+	if err := runAll(c, f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("Unable to write %s. %v", c.out, err)
+	}
+	return nil
+}
+
+// runAll runs the first world and its restarts and writes their statistics
+// to out.
+func runAll(c *config, out io.Writer) error {
+
+	for i := 0; i <= c.restarts; i++ {
+		// Derive the seeds of the restarts from the given one so the whole
+		// set of runs can be repeated.
+		seed := c.seed
+		if seed != 0 {
+			seed += int64(i)
+		}
+
+		replay := c.replay
+		if replay != "" {
+			replay = runFile(replay, i, c.restarts)
+		}
+		o, err := simulate(c, seed, replay)
+		if err != nil {
+			return err
+		}
+		rec := o.stats
+		log.Printf("Run %d (seed %d) ended at chronon %d: %s", i+1, rec.Params.Seed, o.last.Chronon, o.reason)
+
+		if c.format == "jsonl" {
+			err = rec.WriteJSONL(out)
+		} else {
+			err = rec.WriteCSV(out)
+		}
+		if err != nil {
+			return fmt.Errorf("Unable to write statistics. %v", err)
+		}
+
+		charts := []struct {
+			name  string
+			chart *chart.Chart
+		}{
+			{c.plot, chart.Population(rec.Samples)},
+			{c.phase, chart.Phase(rec.Samples)},
+		}
+		for _, ch := range charts {
+			if ch.name == "" {
+				continue
+			}
+			name := runFile(ch.name, i, c.restarts)
+			if err := writeFile(name, func(w io.Writer) error { return ch.chart.Write(w, name) }); err != nil {
+				return err
+			}
+		}
+
+		if c.spatial != "" {
+			write := o.spatial.WriteCSV
+			if strings.ToLower(filepath.Ext(c.spatial)) == ".jsonl" {
+				write = o.spatial.WriteJSONL
+			}
+			if err := writeFile(runFile(c.spatial, i, c.restarts), write); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// runFile returns the file name for a run.  When there are several runs the
+// run number is added before the extension.
+func runFile(name string, run, restarts int) string {
+
+	if restarts == 0 {
+		return name
+	}
+	ext := filepath.Ext(name)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), run+1, ext)
+}
+
+// writeFile creates the file and writes to it.
+func writeFile(name string, write func(io.Writer) error) error {
+
+	f, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("Unable to create %s. %v", name, err)
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("Unable to write %s. %v", name, err)
+	}
+	return f.Close()
+}
+
+// outcome is what was recorded during a run and why it ended.
+type outcome struct {
+	stats   *wator.Recorder
+	spatial *spatial.Recorder // nil unless spatial metrics were asked for
+	last    wator.Stats       // statistics of the chronon the run ended at
+	reason  string
+}
+
+// simulate runs one world until it reaches the number of steps or a stop
+// condition is met.  The replay of the run is written to the file unless
+// its name is empty.
+func simulate(c *config, seed int64, replay string) (*outcome, error) {
+
+	world := wator.Wator{Seed: seed}
+	if err := world.Init(c.width, c.height, c.fish, c.sharks, c.fsr, c.ssr, c.health); err != nil {
+		return nil, err
+	}
+	conditions, err := wator.ParseStopConditions(c.stopOn, c.stop)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the chronons on the -every grid are recorded so that the samples
+	// are evenly spaced, the end of the run is kept apart.
+	o := &outcome{stats: wator.NewRecorder(&world, c.every)}
+	defer o.stats.Stop()
+	if c.spatial != "" {
+		o.spatial = spatial.NewRecorder(&world, c.spatialEvery, c.rdfRadius)
+		defer o.spatial.Stop()
+	}
+
+	var rw *wator.ReplayWriter
+	if replay != "" {
+		if rw, err = wator.CreateReplay(&world, replay); err != nil {
+			return nil, fmt.Errorf("Unable to create %s. %v", replay, err)
+		}
+		defer rw.Stop()
+	}
+
+	stats := world.Stats()
+	reason := wator.CheckStop(&world, stats, conditions...)
+	for i := 0; i < c.steps && reason == ""; i++ {
+		stats = world.Update().Stats
+		reason = wator.CheckStop(&world, stats, conditions...)
+	}
+	if reason == "" {
+		reason = fmt.Sprintf("reached %d steps", c.steps)
+	}
+	o.last, o.reason = stats, reason
+
+	if rw != nil {
+		if err := rw.Stop(); err != nil {
+			return nil, fmt.Errorf("Unable to write %s. %v", replay, err)
+		}
+	}
+
+	return o, nil
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
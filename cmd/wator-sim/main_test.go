@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"lazyhacker.dev/wa-tor/internal/wator"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		wantLines int
+		wantFirst string
+	}{
+		{"csv", []string{"-steps", "10", "-seed", "3"}, 13, "# width=16 height=12 fish=50 sharks=10"},
+		{"csv every 5", []string{"-steps", "10", "-seed", "3", "-every", "5"}, 5, "# width=16"},
+		// The end of the run is off the grid and not recorded.
+		{"csv every 5 to 12", []string{"-steps", "12", "-seed", "3", "-every", "5"}, 5, "# width=16"},
+		{"jsonl", []string{"-steps", "4", "-seed", "3", "-format", "jsonl"}, 6, `{"params":{"width":16`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := run(tc.args, &out); err != nil {
+				t.Fatalf("run(%v): %v", tc.args, err)
+			}
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			if len(lines) != tc.wantLines {
+				t.Errorf("Expected %d lines, got %d", tc.wantLines, len(lines))
+			}
+			if !strings.HasPrefix(lines[0], tc.wantFirst) {
+				t.Errorf("Expected output to start with %q, got %q", tc.wantFirst, lines[0])
+			}
+		})
+	}
+}
+
+func TestRunSameSeed(t *testing.T) {
+	var a, b bytes.Buffer
+	args := []string{"-steps", "50", "-seed", "11"}
+	if err := run(args, &a); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(args, &b); err != nil {
+		t.Fatal(err)
+	}
+	if a.String() != b.String() {
+		t.Error("Expected runs with the same seed to produce the same statistics")
+	}
+}
+
+func TestRunOutputFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.csv")
+	var stdout bytes.Buffer
+	if err := run([]string{"-steps", "2", "-out", path}, &stdout); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("Expected nothing on stdout, got %q", stdout.String())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "# width=16") {
+		t.Errorf("Unexpected file content %q", data)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := [][]string{
+		{"-format", "xml"},
+		{"-steps", "-1"},
+		{"-health", "60"},
+		{"-width", "2", "-height", "2"},
+		{"-stop-on", "forever"},
+		{"-stop", "sharks <"},
+	}
+	for _, args := range tests {
+		if err := run(args, &bytes.Buffer{}); err == nil {
+			t.Errorf("run(%v): expected an error", args)
+		}
+	}
+}
+
+func TestRunStopConditions(t *testing.T) {
+	var out bytes.Buffer
+	args := []string{"-steps", "1000", "-seed", "5", "-stop", "chronon >= 7", "-restarts", "2"}
+	if err := run(args, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	var headers, rows int
+	var last string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		switch {
+		case strings.HasPrefix(line, "# "):
+			headers++
+		case strings.HasPrefix(line, "chronon,"):
+		default:
+			rows++
+			last = line
+		}
+	}
+	if headers != 3 {
+		t.Errorf("Expected 3 runs, got %d", headers)
+	}
+	if rows != 3*8 {
+		t.Errorf("Expected 8 chronons per run, got %d rows", rows)
+	}
+	if !strings.HasPrefix(last, "7,") {
+		t.Errorf("Expected the last run to end at chronon 7, got %q", last)
+	}
+	if !strings.Contains(out.String(), "seed=7 ") {
+		t.Errorf("Expected the restarts to use seeds derived from 5:\n%s", out.String())
+	}
+}
+
+func TestRunStopOnExtinction(t *testing.T) {
+	var out bytes.Buffer
+	// Sharks that can't find fish starve within a few chronons.
+	args := []string{"-fish", "0", "-sharks", "5", "-health", "3", "-every", "100", "-stop-on", "extinction"}
+	if err := run(args, &out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[2], "0,0,5,") {
+		t.Errorf("Expected the run to stop right away with no fish, got:\n%s", out.String())
+	}
+}
+
+func TestRunCharts(t *testing.T) {
+	dir := t.TempDir()
+	args := []string{
+		"-steps", "20", "-restarts", "1",
+		"-plot", filepath.Join(dir, "pop.png"),
+		"-phase", filepath.Join(dir, "phase.svg"),
+	}
+	if err := run(args, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"pop-1.png", "pop-2.png", "phase-1.svg", "phase-2.svg"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil || info.Size() == 0 {
+			t.Errorf("Expected chart %s to be written: %v", name, err)
+		}
+	}
+
+	if err := run([]string{"-plot", "pop.gif"}, &bytes.Buffer{}); err == nil {
+		t.Error("Expected an error for an unknown chart format")
+	}
+}
+
+func TestRunSpatial(t *testing.T) {
+	dir := t.TempDir()
+	csvFile := filepath.Join(dir, "spatial.csv")
+	args := []string{"-steps", "20", "-spatial", csvFile, "-spatial-every", "10", "-rdf-radius", "3"}
+	if err := run(args, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(csvFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Header and chronons 0, 10 and 20.
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 4 {
+		t.Errorf("Expected 4 lines, got %d:\n%s", len(lines), data)
+	}
+
+	jsonFile := filepath.Join(dir, "spatial.jsonl")
+	if err := run([]string{"-steps", "5", "-spatial", jsonFile}, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	data, err = os.ReadFile(jsonFile)
+	if err != nil || !strings.Contains(string(data), `"sizes":[`) {
+		t.Errorf("Expected cluster sizes in the JSON Lines, got %q (%v)", data, err)
+	}
+
+	if err := run([]string{"-spatial", "s.txt"}, &bytes.Buffer{}); err == nil {
+		t.Error("Expected an error for an unknown spatial format")
+	}
+}
+
+func TestRunReplay(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "run.jsonl.gz")
+	args := []string{"-steps", "15", "-seed", "4", "-replay", name}
+	if err := run(args, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := wator.ReadReplay(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Changes) != 15 || r.Start.Params.Seed != 4 {
+		t.Errorf("Expected 15 chronons of seed 4, got %d of seed %d", len(r.Changes), r.Start.Params.Seed)
+	}
+}
@@ -0,0 +1,207 @@
+// Wator-sweep runs the Wa-Tor simulation for every combination of a set of
+// parameters, with several replicate seeds per combination, to find where
+// fish and sharks coexist.  Runs are spread over all CPU cores.
+//
+// Every finished run is appended to the results file so an interrupted sweep
+// picks up where it stopped when started again with the same flags.  When
+// all runs are done, a table summarising each combination is written to
+// stdout.
+//
+// Every parameter takes a list of values and ranges:
+//
+//	wator-sweep -fish 100:500:100 -sharks 10,50 -shark-spawn-rate 20:40:10 -reps 20 -out sweep.csv
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"runtime"
+)
+
+// grid holds the values every parameter takes in the sweep.
+type grid struct {
+	width, height []int
+	fish, sharks  []int
+	fsr, ssr      []int
+	health        []int
+}
+
+// config holds the command-line settings of a sweep.
+type config struct {
+	grid
+	reps    int
+	steps   int
+	workers int
+	seed    int64
+	out     string
+}
+
+// parseFlags reads the settings from the command-line arguments.
+func parseFlags(args []string) (*config, error) {
+
+	c := &config{}
+	fs := flag.NewFlagSet("wator-sweep", flag.ContinueOnError)
+	fish := fs.String("fish", "50", "Initial # of fish.")
+	sharks := fs.String("sharks", "10", "Initial # of sharks.")
+	fsr := fs.String("fish-spawn-rate", "15", "fish spawn rate")
+	ssr := fs.String("shark-spawn-rate", "50", "shark spawn rate")
+	health := fs.String("health", "20", "# of cycles shark can go with feeding before dying.")
+	width := fs.String("width", "16", "number of tiles horizontally (cols)")
+	height := fs.String("height", "12", "number of tiles verticals (rows)")
+	fs.IntVar(&c.reps, "reps", 10, "number of replicate seeds per combination")
+	fs.IntVar(&c.steps, "steps", 1000, "number of chronons per run")
+	fs.IntVar(&c.workers, "workers", runtime.NumCPU(), "number of runs in parallel")
+	fs.Int64Var(&c.seed, "seed", 1, "base seed the seed of every run is derived from")
+	fs.StringVar(&c.out, "out", "sweep.csv", "file the result of every run is appended to")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	values := []struct {
+		name string
+		flag *string
+		dst  *[]int
+	}{
+		{"fish", fish, &c.fish},
+		{"sharks", sharks, &c.sharks},
+		{"fish-spawn-rate", fsr, &c.fsr},
+		{"shark-spawn-rate", ssr, &c.ssr},
+		{"health", health, &c.health},
+		{"width", width, &c.width},
+		{"height", height, &c.height},
+	}
+	for _, v := range values {
+		var err error
+		if *v.dst, err = parseValues(*v.flag); err != nil {
+			return nil, fmt.Errorf("-%s: %v", v.name, err)
+		}
+	}
+
+	if c.reps <= 0 || c.steps < 0 || c.workers <= 0 {
+		return nil, fmt.Errorf("-reps and -workers must be positive and -steps not negative.")
+	}
+
+	return c, nil
+}
+
+// run executes the sweep described by the arguments and writes the summary
+// to stdout.
+func run(ctx context.Context, args []string, stdout io.Writer) error {
+
+	c, err := parseFlags(args)
+	if err != nil {
+		return err
+	}
+
+	// Results of a previous, possibly interrupted, sweep.
+	var results []result
+	if f, err := os.Open(c.out); err == nil {
+		results, err = readResults(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("Unable to read previous results. %v", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	// The key leaves the seed out, so rows of a sweep with another -seed
+	// would pass for the runs of this one.
+	all := combinations(c.grid, c.reps, c.steps, c.seed)
+	seeds := make(map[string]int64, len(all))
+	for _, j := range all {
+		seeds[j.key()] = j.params.Seed
+	}
+	finished := make(map[string]bool)
+	for _, r := range results {
+		if seed, ok := seeds[r.key()]; ok && seed != r.params.Seed {
+			return fmt.Errorf("Results in %s come from another -seed, use another -out file.", c.out)
+		}
+		finished[r.key()] = true
+	}
+	var jobs []job
+	for _, j := range all {
+		if !finished[j.key()] {
+			jobs = append(jobs, j)
+		}
+	}
+	if len(results) > 0 {
+		log.Printf("Resuming sweep: %d runs done, %d to go.", len(results), len(jobs))
+	}
+
+	f, err := openResults(c.out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cw := csv.NewWriter(f)
+	err = sweep(ctx, jobs, c.workers, func(r result) error {
+		results = append(results, r)
+		// Flush every row so an interruption loses at most the runs in
+		// progress.
+		cw.Write(r.record())
+		cw.Flush()
+		return cw.Error()
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeSummary(stdout, summarize(results))
+}
+
+// openResults opens the results file for appending and writes the header if
+// the file is new.  A row cut short by an interruption is terminated so that
+// new rows start on their own line.
+func openResults(path string) (*os.File, error) {
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open results file. %v", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if info.Size() == 0 {
+		cw := csv.NewWriter(f)
+		cw.Write(resultColumns)
+		cw.Flush()
+		err = cw.Error()
+	} else {
+		last := make([]byte, 1)
+		if _, err = f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			_, err = f.Write([]byte("\n"))
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stdout); err != nil {
+		if errors.Is(err, context.Canceled) {
+			log.Fatal("Sweep interrupted, run again with the same flags to resume.")
+		}
+		log.Fatal(err)
+	}
+}
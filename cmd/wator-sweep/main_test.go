@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseValues(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []int
+		wantErr bool
+	}{
+		{"10", []int{10}, false},
+		{"5,10,20", []int{5, 10, 20}, false},
+		{"10:30:10", []int{10, 20, 30}, false},
+		{"1:3,10", []int{1, 2, 3, 10}, false},
+		{"10:25:10", []int{10, 20}, false},
+		{"", nil, true},
+		{"a", nil, true},
+		{"5:1", nil, true},
+		{"1:5:0", nil, true},
+		{"1:2:3:4", nil, true},
+	}
+	for _, tc := range tests {
+		got, err := parseValues(tc.in)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("parseValues(%q) error = %v; expected error: %v", tc.in, err, tc.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("parseValues(%q) = %v, expected %v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestCombinations(t *testing.T) {
+	g := grid{
+		width: []int{4}, height: []int{4},
+		fish: []int{4, 20}, sharks: []int{2},
+		fsr: []int{3}, ssr: []int{3, 5}, health: []int{4},
+	}
+	// 20 fish don't fit and health 4 is above a shark spawn rate of 3.
+	jobs := combinations(g, 3, 10, 1)
+	if len(jobs) != 3 {
+		t.Fatalf("Expected 3 jobs, got %d", len(jobs))
+	}
+	seeds := make(map[int64]bool)
+	for _, j := range jobs {
+		seeds[j.params.Seed] = true
+	}
+	if len(seeds) != 3 {
+		t.Errorf("Expected a different seed per replicate, got %v", seeds)
+	}
+	if again := combinations(g, 3, 10, 1); !reflect.DeepEqual(jobs, again) {
+		t.Error("Expected the same seeds for the same sweep")
+	}
+}
+
+func TestCombinationsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		g    grid
+	}{
+		{"health", grid{width: []int{4}, height: []int{4}, fish: []int{4}, sharks: []int{2}, fsr: []int{3}, ssr: []int{10}, health: []int{0, 5}}},
+		{"size", grid{width: []int{0, 4}, height: []int{4}, fish: []int{0}, sharks: []int{0}, fsr: []int{3}, ssr: []int{10}, health: []int{5}}},
+		{"counts", grid{width: []int{4}, height: []int{4}, fish: []int{-1, 4}, sharks: []int{2}, fsr: []int{3}, ssr: []int{10}, health: []int{5}}},
+	}
+	for _, tc := range tests {
+		jobs := combinations(tc.g, 1, 10, 1)
+		if len(jobs) != 1 {
+			t.Fatalf("%s: expected the invalid combination to be left out, got %d jobs", tc.name, len(jobs))
+		}
+		if _, err := simulate(jobs[0]); err != nil {
+			t.Errorf("%s: expected the remaining job to run, got %v", tc.name, err)
+		}
+	}
+}
+
+func TestResultRecord(t *testing.T) {
+	jobs := combinations(grid{
+		width: []int{8}, height: []int{8}, fish: []int{20}, sharks: []int{5},
+		fsr: []int{3}, ssr: []int{6}, health: []int{4},
+	}, 1, 30, 1)
+	r, err := simulate(jobs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := parseResult(r.record())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, r) {
+		t.Errorf("parseResult(record()) = %+v, expected %+v", got, r)
+	}
+}
+
+func TestRunResume(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "sweep.csv")
+	args := []string{
+		"-width", "8", "-height", "8", "-fish", "20,30", "-sharks", "5",
+		"-fish-spawn-rate", "3", "-shark-spawn-rate", "6", "-health", "4",
+		"-reps", "3", "-steps", "50", "-workers", "4", "-out", out,
+	}
+
+	var full bytes.Buffer
+	if err := run(context.Background(), args, &full); err != nil {
+		t.Fatal(err)
+	}
+	summary := strings.Split(strings.TrimSpace(full.String()), "\n")
+	if len(summary) != 3 {
+		t.Fatalf("Expected header and 2 combinations, got:\n%s", full.String())
+	}
+
+	// Simulate an interruption: keep the header, 2 rows and half a row.
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(data), "\n")
+	if len(lines) != 8 {
+		t.Fatalf("Expected header and 6 runs, got %d lines", len(lines)-1)
+	}
+	partial := strings.Join(lines[:3], "\n") + "\n" + lines[3][:5]
+	if err := os.WriteFile(out, []byte(partial), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var resumed bytes.Buffer
+	if err := run(context.Background(), args, &resumed); err != nil {
+		t.Fatal(err)
+	}
+	if resumed.String() != full.String() {
+		t.Errorf("Expected resumed sweep to match:\n%s\ngot:\n%s", full.String(), resumed.String())
+	}
+
+	// The runs of another seed are not taken as done.
+	err = run(context.Background(), append(args, "-seed", "7"), io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "another -seed") {
+		t.Errorf("Expected a sweep with another seed to be refused, got %v", err)
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	out := filepath.Join(t.TempDir(), "sweep.csv")
+	err := run(ctx, []string{"-reps", "50", "-steps", "10", "-out", out}, &bytes.Buffer{})
+	if err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
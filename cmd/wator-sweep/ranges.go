@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseValues reads the values a parameter takes in the sweep.  Values are
+// separated by commas and each one is either a number or a range written as
+// start:end or start:end:step with end included.
+//
+//	"10"        -> 10
+//	"5,10,20"   -> 5, 10, 20
+//	"10:30:10"  -> 10, 20, 30
+//	"1:3,10"    -> 1, 2, 3, 10
+func parseValues(s string) ([]int, error) {
+
+	var values []int
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		parts := strings.Split(item, ":")
+		if len(parts) > 3 {
+			return nil, fmt.Errorf("Invalid range %q.", item)
+		}
+
+		bounds := make([]int, len(parts))
+		for i, p := range parts {
+			n, err := strconv.Atoi(strings.TrimSpace(p))
+			if err != nil {
+				return nil, fmt.Errorf("Invalid number in %q. %v", item, err)
+			}
+			bounds[i] = n
+		}
+
+		if len(bounds) == 1 {
+			values = append(values, bounds[0])
+			continue
+		}
+
+		step := 1
+		if len(bounds) == 3 {
+			step = bounds[2]
+		}
+		if step <= 0 || bounds[1] < bounds[0] {
+			return nil, fmt.Errorf("Invalid range %q, expected start <= end and a positive step.", item)
+		}
+		for v := bounds[0]; v <= bounds[1]; v += step {
+			values = append(values, v)
+		}
+	}
+
+	if len(values) == 0 {
+		return nil, fmt.Errorf("No values in %q.", s)
+	}
+
+	return values, nil
+}
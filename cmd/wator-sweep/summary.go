@@ -0,0 +1,121 @@
+package main
+
+import (
+	"cmp"
+	"encoding/csv"
+	"io"
+	"slices"
+	"strconv"
+
+	"lazyhacker.dev/wa-tor/internal/analysis"
+	"lazyhacker.dev/wa-tor/internal/wator"
+)
+
+// summary aggregates the replicate runs of one combination of parameters.
+type summary struct {
+	params        wator.Params // Seed is not set.
+	steps         int
+	runs          int
+	coexist       int // runs where neither species went extinct
+	fishExtinct   []float64
+	sharksExtinct []float64
+	meanFish      []float64
+	meanSharks    []float64
+	periods       []float64 // periods of the runs that oscillated
+}
+
+// summaryColumns are the columns of the summary table.
+var summaryColumns = []string{
+	"width", "height", "fish", "sharks", "fish_spawn_rate", "shark_spawn_rate",
+	"health", "steps", "runs", "coexistence", "fish_extinctions",
+	"mean_fish_extinct", "sharks_extinctions", "mean_sharks_extinct",
+	"mean_fish", "mean_sharks", "mean_period",
+}
+
+// summarize groups the results by combination of parameters.  The results
+// are sorted first so that the summary doesn't depend on the order the runs
+// finished in.
+func summarize(results []result) []*summary {
+
+	results = slices.Clone(results)
+	slices.SortFunc(results, func(a, b result) int {
+		pa, pb := a.params, b.params
+		return cmp.Or(
+			cmp.Compare(pa.Width, pb.Width),
+			cmp.Compare(pa.Height, pb.Height),
+			cmp.Compare(pa.Fish, pb.Fish),
+			cmp.Compare(pa.Sharks, pb.Sharks),
+			cmp.Compare(pa.FishSpawnRate, pb.FishSpawnRate),
+			cmp.Compare(pa.SharkSpawnRate, pb.SharkSpawnRate),
+			cmp.Compare(pa.Health, pb.Health),
+			cmp.Compare(a.steps, b.steps),
+			cmp.Compare(a.rep, b.rep),
+		)
+	})
+
+	var order []*summary
+	groups := make(map[string]*summary)
+	for _, r := range results {
+		j := r.job
+		j.rep = 0
+		k := j.key()
+		s, ok := groups[k]
+		if !ok {
+			p := r.params
+			p.Seed = 0
+			s = &summary{params: p, steps: r.steps}
+			groups[k] = s
+			order = append(order, s)
+		}
+
+		s.runs++
+		if r.fishExtinct < 0 && r.sharksExtinct < 0 {
+			s.coexist++
+		}
+		if r.fishExtinct >= 0 {
+			s.fishExtinct = append(s.fishExtinct, float64(r.fishExtinct))
+		}
+		if r.sharksExtinct >= 0 {
+			s.sharksExtinct = append(s.sharksExtinct, float64(r.sharksExtinct))
+		}
+		s.meanFish = append(s.meanFish, r.meanFish)
+		s.meanSharks = append(s.meanSharks, r.meanSharks)
+		if r.period > 0 {
+			s.periods = append(s.periods, float64(r.period))
+		}
+	}
+
+	return order
+}
+
+// writeSummary writes the summaries as a CSV table.  Averages of extinction
+// chronons and periods only include the runs where they happened.
+func writeSummary(out io.Writer, summaries []*summary) error {
+
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+
+	cw := csv.NewWriter(out)
+	if err := cw.Write(summaryColumns); err != nil {
+		return err
+	}
+	for _, s := range summaries {
+		p := s.params
+		row := []string{
+			strconv.Itoa(p.Width), strconv.Itoa(p.Height),
+			strconv.Itoa(p.Fish), strconv.Itoa(p.Sharks),
+			strconv.Itoa(p.FishSpawnRate), strconv.Itoa(p.SharkSpawnRate),
+			strconv.Itoa(p.Health), strconv.Itoa(s.steps),
+			strconv.Itoa(s.runs),
+			f(float64(s.coexist) / float64(s.runs)),
+			strconv.Itoa(len(s.fishExtinct)), f(analysis.Mean(s.fishExtinct)),
+			strconv.Itoa(len(s.sharksExtinct)), f(analysis.Mean(s.sharksExtinct)),
+			f(analysis.Mean(s.meanFish)), f(analysis.Mean(s.meanSharks)), f(analysis.Mean(s.periods)),
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
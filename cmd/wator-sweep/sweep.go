@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strconv"
+	"sync"
+
+	"lazyhacker.dev/wa-tor/internal/analysis"
+	"lazyhacker.dev/wa-tor/internal/wator"
+)
+
+// job is one run of the sweep: a combination of parameters and the replicate
+// number.  The seed in the parameters is derived from both so that a resumed
+// sweep runs exactly the same worlds.
+type job struct {
+	params wator.Params
+	rep    int
+	steps  int
+}
+
+// key identifies a job independently of its seed.
+func (j job) key() string {
+	p := j.params
+	p.Seed = 0
+	return fmt.Sprintf("%v rep=%d steps=%d", p, j.rep, j.steps)
+}
+
+// result is the outcome of one run.  Extinction chronons are -1 if the species
+// survived the whole run.
+type result struct {
+	job
+	fishExtinct   int
+	sharksExtinct int
+	meanFish      float64
+	meanSharks    float64
+	period        int
+}
+
+// resultColumns are the columns of the per-run results file.
+var resultColumns = []string{
+	"width", "height", "fish", "sharks", "fish_spawn_rate", "shark_spawn_rate",
+	"health", "rep", "seed", "steps", "fish_extinct", "sharks_extinct",
+	"mean_fish", "mean_sharks", "period",
+}
+
+// combinations returns every job of the sweep.  Combinations the engine
+// would reject, as Params.Validate tells, are left out.
+func combinations(g grid, reps, steps int, baseSeed int64) []job {
+
+	var jobs []job
+	for _, w := range g.width {
+		for _, h := range g.height {
+			for _, f := range g.fish {
+				for _, s := range g.sharks {
+					for _, fsr := range g.fsr {
+						for _, ssr := range g.ssr {
+							for _, hp := range g.health {
+								p := wator.Params{
+									Width: w, Height: h, Fish: f, Sharks: s,
+									FishSpawnRate: fsr, SharkSpawnRate: ssr, Health: hp,
+								}
+								if p.Validate() != nil {
+									continue
+								}
+								for r := 0; r < reps; r++ {
+									j := job{params: p, rep: r, steps: steps}
+									j.params.Seed = runSeed(baseSeed, j)
+									jobs = append(jobs, j)
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return jobs
+}
+
+// runSeed derives the seed of a run from the base seed of the sweep and the
+// job so that it doesn't depend on the order runs are executed in.
+func runSeed(base int64, j job) int64 {
+
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d %s", base, j.key())
+	seed := int64(h.Sum64() >> 1)
+	if seed == 0 {
+		seed = 1
+	}
+	return seed
+}
+
+// simulate runs a world for the number of steps of the job.
+func simulate(j job) (result, error) {
+
+	p := j.params
+	world := wator.Wator{Seed: p.Seed}
+	if err := world.Init(p.Width, p.Height, p.Fish, p.Sharks, p.FishSpawnRate, p.SharkSpawnRate, p.Health); err != nil {
+		return result{}, err
+	}
+
+	r := result{job: j, fishExtinct: -1, sharksExtinct: -1}
+	fish := make([]float64, 0, j.steps+1)
+	sharks := make([]float64, 0, j.steps+1)
+
+	stats := world.Stats()
+	for i := 0; ; i++ {
+		fish = append(fish, float64(stats.Fish))
+		sharks = append(sharks, float64(stats.Sharks))
+		if stats.Fish == 0 && r.fishExtinct < 0 {
+			r.fishExtinct = int(stats.Chronon)
+		}
+		if stats.Sharks == 0 && r.sharksExtinct < 0 {
+			r.sharksExtinct = int(stats.Chronon)
+		}
+		if i == j.steps {
+			break
+		}
+		stats = world.Update().Stats
+	}
+
+	r.meanFish = analysis.Mean(fish)
+	r.meanSharks = analysis.Mean(sharks)
+	r.period = analysis.Period(fish)
+
+	return r, nil
+}
+
+// outcome is a result or the error that prevented it.
+type outcome struct {
+	result
+	err error
+}
+
+// sweep runs the jobs on a pool of workers and hands every result to emit as
+// soon as it is available.  emit is never called concurrently.  Cancelling
+// the context stops the sweep after the runs in progress.
+func sweep(ctx context.Context, jobs []job, workers int, emit func(result) error) error {
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	todo := make(chan job)
+	done := make(chan outcome)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range todo {
+				r, err := simulate(j)
+				done <- outcome{r, err}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(todo)
+		for _, j := range jobs {
+			select {
+			case todo <- j:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	// Keep draining so that the workers can finish after an error.
+	var err error
+	for o := range done {
+		if err != nil {
+			continue
+		}
+		err = o.err
+		if err == nil {
+			err = emit(o.result)
+		}
+		if err != nil {
+			cancel()
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return ctx.Err()
+}
+
+// record returns the result as a row of the results file.
+func (r result) record() []string {
+
+	p := r.params
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+
+	return []string{
+		strconv.Itoa(p.Width), strconv.Itoa(p.Height),
+		strconv.Itoa(p.Fish), strconv.Itoa(p.Sharks),
+		strconv.Itoa(p.FishSpawnRate), strconv.Itoa(p.SharkSpawnRate),
+		strconv.Itoa(p.Health), strconv.Itoa(r.rep),
+		strconv.FormatInt(p.Seed, 10), strconv.Itoa(r.steps),
+		strconv.Itoa(r.fishExtinct), strconv.Itoa(r.sharksExtinct),
+		f(r.meanFish), f(r.meanSharks), strconv.Itoa(r.period),
+	}
+}
+
+// parseResult reads a row of the results file.
+func parseResult(row []string) (result, error) {
+
+	if len(row) != len(resultColumns) {
+		return result{}, fmt.Errorf("Expected %d columns, got %d.", len(resultColumns), len(row))
+	}
+
+	ints := make([]int, len(row))
+	for i, v := range row {
+		switch resultColumns[i] {
+		case "seed", "mean_fish", "mean_sharks":
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return result{}, fmt.Errorf("Invalid %s %q.", resultColumns[i], v)
+		}
+		ints[i] = n
+	}
+	seed, err := strconv.ParseInt(row[8], 10, 64)
+	if err != nil {
+		return result{}, fmt.Errorf("Invalid seed %q.", row[8])
+	}
+	meanFish, err := strconv.ParseFloat(row[12], 64)
+	if err != nil {
+		return result{}, fmt.Errorf("Invalid mean_fish %q.", row[12])
+	}
+	meanSharks, err := strconv.ParseFloat(row[13], 64)
+	if err != nil {
+		return result{}, fmt.Errorf("Invalid mean_sharks %q.", row[13])
+	}
+
+	return result{
+		job: job{
+			params: wator.Params{
+				Width: ints[0], Height: ints[1], Fish: ints[2], Sharks: ints[3],
+				FishSpawnRate: ints[4], SharkSpawnRate: ints[5], Health: ints[6],
+				Seed: seed,
+			},
+			rep:   ints[7],
+			steps: ints[9],
+		},
+		fishExtinct:   ints[10],
+		sharksExtinct: ints[11],
+		meanFish:      meanFish,
+		meanSharks:    meanSharks,
+		period:        ints[14],
+	}, nil
+}
+
+// readResults reads the rows of a results file.  Rows that cannot be read,
+// such as one cut short by an interruption, are skipped.
+func readResults(in io.Reader) ([]result, error) {
+
+	cr := csv.NewReader(in)
+	cr.FieldsPerRecord = -1
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []result
+	for i, row := range rows {
+		if i == 0 && len(row) > 0 && row[0] == resultColumns[0] {
+			continue
+		}
+		r, err := parseResult(row)
+		if err != nil {
+			continue
+		}
+		results = append(results, r)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math/rand"
+
+	"lazyhacker.dev/wa-tor/internal/wator"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const maxUndo = 100 // strokes the editor can undo
+
+// Tools of the editor.
+const (
+	toolBrush = iota // paints the tiles under the brush
+	toolRect         // fills the rectangle dragged out
+	toolSpray        // paints a random part of the tiles under the brush
+)
+
+var toolNames = []string{"brush", "rectangle", "spray"}
+
+// editor paints creatures onto the world with the mouse.  Every stroke can
+// be undone, the world before it is kept as a snapshot.
+type editor struct {
+	tool     int
+	species  int // FISH, SHARK or NONE to erase
+	size     int // width of the brush in tiles
+	density  int // percent of the tiles the spray paints
+	painting bool
+	erasing  bool         // the stroke is with the right button
+	start    int          // tile the stroke started on
+	end      int          // tile the stroke is on
+	touched  map[int]bool // tiles the stroke painted or skipped
+	undo     []wator.Snapshot
+	redo     []wator.Snapshot
+	changed  bool // the world differs from the chronon it had
+}
+
+// brushTiles returns the tiles of a square brush size tiles wide centered
+// on the tile.  The brush wraps around the edges like the world.
+func brushTiles(center, size, width, height int) []int {
+
+	row, col := center/width, center%width
+	var tiles []int
+	for dy := -(size - 1) / 2; dy <= size/2; dy++ {
+		for dx := -(size - 1) / 2; dx <= size/2; dx++ {
+			r := ((row+dy)%height + height) % height
+			c := ((col+dx)%width + width) % width
+			tiles = append(tiles, r*width+c)
+		}
+	}
+	return tiles
+}
+
+// rectTiles returns the tiles of the rectangle with the corners a and b.
+func rectTiles(a, b, width int) []int {
+
+	top, bottom := min(a/width, b/width), max(a/width, b/width)
+	left, right := min(a%width, b%width), max(a%width, b%width)
+	var tiles []int
+	for r := top; r <= bottom; r++ {
+		for c := left; c <= right; c++ {
+			tiles = append(tiles, r*width+c)
+		}
+	}
+	return tiles
+}
+
+// startEditor pauses the game and edits the shown chronon.  Editing a chronon
+// the game stepped back to continues the world from it.
+func (g *Game) startEditor() {
+
+	e := &editor{tool: toolBrush, species: wator.FISH, size: 1, density: 30}
+	if g.history.rewound() {
+		if err := g.restoreShown(); err != nil {
+			g.status = fmt.Sprintf("Unable to edit: %v", err)
+			return
+		}
+		e.changed = true
+	}
+	g.editor = e
+	g.pause = true
+	g.panelOpen = false
+	g.show(g.world.State())
+}
+
+// stopEditor leaves the editor.  The run continues from the edited world, so
+// the chronons kept and the replay start over.
+func (g *Game) stopEditor() {
+
+	e := g.editor
+	g.editor = nil
+	if !e.changed {
+		return
+	}
+
+	conditions, err := g.newStopConditions()
+	if err != nil {
+		g.status = fmt.Sprintf("Unable to check the stop conditions: %v", err)
+	} else {
+		g.stopConditions = conditions
+	}
+	g.ended = ""
+	g.resetHistory()
+	g.startRecording()
+}
+
+// updateEditor handles the keys and the mouse in the editor.
+func (g *Game) updateEditor() error {
+
+	e := g.editor
+	ctrl := ebiten.IsKeyPressed(ebiten.KeyControl)
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape), inpututil.IsKeyJustPressed(ebiten.KeyE):
+		g.stopEditor()
+		return nil
+	case ctrl && inpututil.IsKeyJustPressed(ebiten.KeyY),
+		ctrl && ebiten.IsKeyPressed(ebiten.KeyShift) && inpututil.IsKeyJustPressed(ebiten.KeyZ):
+		g.redoEdit()
+	case ctrl && inpututil.IsKeyJustPressed(ebiten.KeyZ):
+		g.undoEdit()
+	case ctrl && inpututil.IsKeyJustPressed(ebiten.KeyS):
+		g.status = g.saveSnapshot(*snapshot)
+	case inpututil.IsKeyJustPressed(ebiten.Key1):
+		e.species = wator.FISH
+	case inpututil.IsKeyJustPressed(ebiten.Key2):
+		e.species = wator.SHARK
+	case inpututil.IsKeyJustPressed(ebiten.Key3):
+		e.species = wator.NONE
+	case inpututil.IsKeyJustPressed(ebiten.KeyB):
+		e.tool = toolBrush
+	case inpututil.IsKeyJustPressed(ebiten.KeyR):
+		e.tool = toolRect
+	case inpututil.IsKeyJustPressed(ebiten.KeyS):
+		e.tool = toolSpray
+	case inpututil.IsKeyJustPressed(ebiten.KeyBracketLeft):
+		e.size = max(e.size-1, 1)
+	case inpututil.IsKeyJustPressed(ebiten.KeyBracketRight):
+		e.size = min(e.size+1, max(g.world.Width, g.world.Height))
+	case inpututil.IsKeyJustPressed(ebiten.KeyComma):
+		e.density = max(e.density-10, 10)
+	case inpututil.IsKeyJustPressed(ebiten.KeyPeriod):
+		e.density = min(e.density+10, 100)
+	}
+
+	tile, inside := g.cursorTile()
+	left := ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft)
+	right := ebiten.IsMouseButtonPressed(ebiten.MouseButtonRight)
+	if !e.painting && inside && (inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) ||
+		inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight)) {
+		g.beginStroke(tile, !left)
+	}
+	if !e.painting {
+		return nil
+	}
+
+	if left || right {
+		if inside {
+			e.end = tile
+			if e.tool != toolRect {
+				g.paint(brushTiles(tile, e.size, g.world.Width, g.world.Height))
+			}
+		}
+		return nil
+	}
+	if e.tool == toolRect {
+		g.paint(rectTiles(e.start, e.end, g.world.Width))
+	}
+	e.painting = false
+	return nil
+}
+
+// beginStroke starts painting at the tile.  The world before the stroke is
+// kept to undo it.
+func (g *Game) beginStroke(tile int, erasing bool) {
+
+	e := g.editor
+	e.undo = append(e.undo, g.world.Snapshot())
+	if len(e.undo) > maxUndo {
+		e.undo = e.undo[1:]
+	}
+	e.redo = nil
+	e.painting, e.erasing, e.changed = true, erasing, true
+	e.start, e.end = tile, tile
+	e.touched = make(map[int]bool)
+}
+
+// paint puts the creatures of the stroke on the tiles.  Each tile is only
+// painted once a stroke, so that the spray keeps its density.
+func (g *Game) paint(tiles []int) {
+
+	e := g.editor
+	species := e.species
+	if e.erasing {
+		species = wator.NONE
+	}
+	for _, t := range tiles {
+		if e.touched[t] {
+			continue
+		}
+		e.touched[t] = true
+		if e.tool == toolSpray && rand.Intn(100) >= e.density {
+			continue
+		}
+		if err := g.world.Place(t, species); err != nil {
+			g.status = fmt.Sprintf("Unable to paint: %v", err)
+		}
+	}
+	g.show(g.world.State())
+}
+
+// undoEdit takes back the last stroke.
+func (g *Game) undoEdit() {
+
+	e := g.editor
+	if len(e.undo) == 0 {
+		g.status = "Nothing to undo"
+		return
+	}
+	e.redo = append(e.redo, g.world.Snapshot())
+	g.restoreEdit(e.undo[len(e.undo)-1])
+	e.undo = e.undo[:len(e.undo)-1]
+}
+
+// redoEdit paints the last stroke that was taken back again.
+func (g *Game) redoEdit() {
+
+	e := g.editor
+	if len(e.redo) == 0 {
+		g.status = "Nothing to redo"
+		return
+	}
+	e.undo = append(e.undo, g.world.Snapshot())
+	g.restoreEdit(e.redo[len(e.redo)-1])
+	e.redo = e.redo[:len(e.redo)-1]
+}
+
+// restoreEdit puts the world back to the snapshot taken by the editor.
+func (g *Game) restoreEdit(snap wator.Snapshot) {
+
+	if err := g.world.Restore(snap); err != nil {
+		g.status = fmt.Sprintf("Unable to undo: %v", err)
+		return
+	}
+	g.status = ""
+	g.show(g.world.State())
+}
+
+// editorStatus describes the brush of the editor.
+func (g *Game) editorStatus() string {
+
+	e := g.editor
+	what := map[int]string{wator.FISH: "fish", wator.SHARK: "shark", wator.NONE: "erase"}[e.species]
+	s := fmt.Sprintf("EDIT %s with %s", what, toolNames[e.tool])
+	if e.tool != toolRect {
+		s += fmt.Sprintf(" %dx%d", e.size, e.size)
+	}
+	if e.tool == toolSpray {
+		s += fmt.Sprintf(" at %d%%", e.density)
+	}
+	return s
+}
+
+// DrawEditor draws the outline of the brush or the rectangle being dragged
+// out and the keys of the editor.
+func (g *Game) DrawEditor(screen *ebiten.Image) {
+
+	e := g.editor
+	outline := color.RGBA{255, 220, 80, 255}
+	var x, y, w, h float64 // Outlined area in world pixels.
+	switch tile, inside := g.cursorTile(); {
+	case e.painting && e.tool == toolRect:
+		tiles := rectTiles(e.start, e.end, g.world.Width)
+		x0, y0 := g.TileCoordinate(tiles[0])
+		x1, y1 := g.TileCoordinate(tiles[len(tiles)-1])
+		x, y, w, h = x0, y0, x1-x0+TileSize, y1-y0+TileSize
+	case inside:
+		size := e.size
+		if e.tool == toolRect {
+			size = 1
+		}
+		cx, cy := g.TileCoordinate(tile)
+		off := float64((size - 1) / 2 * TileSize)
+		x, y, w, h = cx-off, cy-off, float64(size*TileSize), float64(size*TileSize)
+	}
+	if w > 0 {
+		g.onScreen(x, y, w, h, func(sx, sy float64) {
+			vector.StrokeRect(screen, float32(sx), float32(sy), float32(w*g.cam.zoom), float32(h*g.cam.zoom), 2, outline, false)
+		})
+	}
+
+	ebitenutil.DebugPrintAt(screen, "LEFT paints, RIGHT erases.  1/2/3 fish/shark/erase, B/R/S brush/rectangle/spray,\n[ ] brush size, , . spray density, Ctrl+Z/Y undo/redo, Ctrl+S save, E done.", 0, 32)
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"slices"
+	"unsafe"
+
+	"lazyhacker.dev/wa-tor/internal/wator"
+)
+
+// keyframeInterval is the number of chronons between the complete worlds
+// the history keeps, like the states a replay Player keeps to seek.
+const keyframeInterval = 64
+
+// historyEntry is a chronon of a live world kept to step back to it.  Only
+// keyframes keep the complete world, the chronons after one are rebuilt by
+// running the world again from it.
+type historyEntry struct {
+	stats    wator.Stats     // Statistics of the chronon.
+	changes  []wator.Delta   // ChangeLog leading to the chronon from the one before.
+	keyframe *wator.Snapshot // World at the end of the chronon, nil between keyframes.
+	size     int             // Approximate number of bytes used by the entry.
+}
+
+// newHistoryEntry returns the entry for the current chronon of the world,
+// with the complete world if it is a keyframe.
+func newHistoryEntry(w *wator.Wator, changes []wator.Delta, keyframe bool) historyEntry {
+
+	e := historyEntry{stats: w.Stats(), changes: changes}
+	e.size = int(unsafe.Sizeof(e)) + len(changes)*int(unsafe.Sizeof(wator.Delta{}))
+	if keyframe {
+		snap := w.Snapshot()
+		e.keyframe = &snap
+		e.size += len(snap.Creatures) * int(unsafe.Sizeof(wator.Creature{}))
+	}
+	return e
+}
+
+// history is a ring buffer of the most recent chronons of a live world.  It
+// keeps at most depth chronons and drops the oldest ones when the entries
+// use more than maxSize bytes.  The cursor is the chronon on the screen,
+// which is the newest one unless the game stepped back.  The positions of
+// the creatures of the shown chronon follow the ChangeLogs as the cursor
+// moves and the complete world is only rebuilt when it is asked for.
+type history struct {
+	entries []historyEntry
+	first   int // Index of the oldest entry in entries.
+	count   int // Number of entries kept.
+	size    int // Bytes used by the entries.
+	maxSize int
+	cursor  int              // Position of the shown chronon, 0 is the oldest.
+	shown   wator.WorldState // Positions of the creatures of the shown chronon.
+
+	replay   wator.Wator    // World run again from a keyframe.
+	replayAt int            // Position of the chronon of replay, -1 for none.
+	rebuilt  wator.Snapshot // Snapshot of replay.
+}
+
+// newHistory returns an empty history of at most depth chronons and maxSize
+// bytes.  The newest chronon is always kept, whatever its size.
+func newHistory(depth, maxSize int) *history {
+	return &history{entries: make([]historyEntry, max(depth, 1)), maxSize: maxSize, replayAt: -1}
+}
+
+// at returns the entry at the position, 0 being the oldest.
+func (h *history) at(i int) *historyEntry {
+	return &h.entries[(h.first+i)%len(h.entries)]
+}
+
+// current returns the entry of the shown chronon.
+func (h *history) current() *historyEntry {
+	return h.at(h.cursor)
+}
+
+// rewound reports whether the shown chronon is older than the newest one.
+func (h *history) rewound() bool {
+	return h.cursor < h.count-1
+}
+
+// behind returns how many chronons the shown one is older than the newest.
+func (h *history) behind() int {
+	return h.count - 1 - h.cursor
+}
+
+// state returns the positions of the creatures of the shown chronon.
+func (h *history) state() wator.WorldState {
+	return slices.Clone(h.shown)
+}
+
+// keyframeBefore returns the position of the latest keyframe at or before
+// the position.
+func (h *history) keyframeBefore(i int) int {
+
+	for i > 0 && h.at(i).keyframe == nil {
+		i--
+	}
+	return i
+}
+
+// push adds the current chronon of the world, reached by the changes, as
+// the newest one and shows it.  Chronons newer than the shown one are
+// dropped first as the new one branches from it.  The complete world is
+// kept every keyframeInterval chronons and whenever no keyframe is left to
+// rebuild the chronon from.
+func (h *history) push(w *wator.Wator, changes []wator.Delta) {
+
+	h.truncate()
+	if h.count == len(h.entries) {
+		h.dropOldest()
+	}
+	if h.count == 0 {
+		h.shown = w.State()
+	} else {
+		h.shown.Apply(changes)
+	}
+
+	keyframe := h.count == 0 || h.count-h.keyframeBefore(h.count-1) >= keyframeInterval
+	e := newHistoryEntry(w, changes, keyframe)
+	*h.at(h.count) = e
+	h.count++
+	h.size += e.size
+	h.cursor = h.count - 1
+	for h.size > h.maxSize && h.count > 1 {
+		h.dropOldest()
+	}
+}
+
+// rekey keeps the complete world of the newest chronon, which must be the
+// world's current one.  It is needed when the world changed other than by
+// running, for a new seed or new rules, as running the world again from an
+// earlier keyframe would not reach the chronons after it.
+func (h *history) rekey(w *wator.Wator) {
+
+	e := h.at(h.count - 1)
+	h.size -= e.size
+	*e = newHistoryEntry(w, e.changes, true)
+	h.size += e.size
+	h.replayAt = -1
+}
+
+// dropOldest forgets the oldest chronon.  The chronon after it becomes a
+// keyframe, when it isn't one, by running the world of the dropped one
+// again, so that the chronons after it can still be rebuilt.
+func (h *history) dropOldest() {
+
+	dropped := h.forgetOldest()
+	if h.count == 0 || h.at(0).keyframe != nil {
+		h.replayAt = max(h.replayAt, -1)
+		return
+	}
+
+	// replay is often at the dropped chronon, or even the one after it, from
+	// the previous drop.
+	switch h.replayAt {
+	case 0:
+	case -1:
+		h.replay.Update()
+	default:
+		if err := h.replay.Restore(*dropped.keyframe); err != nil {
+			// The chronons up to the next keyframe can't be rebuilt.
+			h.replayAt = -1
+			for h.count > 1 && h.at(0).keyframe == nil {
+				h.forgetOldest()
+			}
+			h.replayAt = max(h.replayAt, -1)
+			return
+		}
+		h.replay.Update()
+	}
+	h.replayAt = 0
+	h.rebuilt = h.replay.Snapshot()
+	keyframe := h.rebuilt
+	e := h.at(0)
+	h.size -= e.size
+	e.keyframe = &keyframe
+	e.size += len(keyframe.Creatures) * int(unsafe.Sizeof(wator.Creature{}))
+	h.size += e.size
+}
+
+// forgetOldest removes the oldest chronon and returns it.  replayAt follows
+// the positions and is below -1 when replay is older than the chronons kept.
+func (h *history) forgetOldest() historyEntry {
+
+	e := *h.at(0)
+	h.size -= e.size
+	*h.at(0) = historyEntry{}
+	h.first = (h.first + 1) % len(h.entries)
+	h.count--
+	h.cursor = max(h.cursor-1, 0)
+	h.replayAt--
+	return e
+}
+
+// truncate forgets the chronons newer than the shown one.
+func (h *history) truncate() {
+
+	for h.count > h.cursor+1 {
+		h.count--
+		h.size -= h.at(h.count).size
+		*h.at(h.count) = historyEntry{}
+	}
+	if h.replayAt > h.cursor {
+		h.replayAt = -1
+	}
+}
+
+// back moves to the previous chronon and returns the changes that undo the
+// one that was shown.  It returns false at the oldest chronon.
+func (h *history) back() ([]wator.Delta, bool) {
+
+	if h.cursor == 0 {
+		return nil, false
+	}
+	changes := wator.Invert(h.current().changes)
+	h.shown.Apply(changes)
+	h.cursor--
+	return changes, true
+}
+
+// forward moves to the next chronon and returns its changes.  It returns
+// false at the newest chronon.
+func (h *history) forward() ([]wator.Delta, bool) {
+
+	if !h.rewound() {
+		return nil, false
+	}
+	h.cursor++
+	h.shown.Apply(h.current().changes)
+	return h.current().changes, true
+}
+
+// snapshot returns the complete world of the shown chronon.  Between
+// keyframes the world is run again from the keyframe before the chronon, or
+// from the chronon rebuilt last when it is on the way.
+func (h *history) snapshot() (wator.Snapshot, error) {
+
+	if k := h.current().keyframe; k != nil {
+		return *k, nil
+	}
+	k := h.keyframeBefore(h.cursor)
+	if h.replayAt < k || h.replayAt > h.cursor {
+		if err := h.replay.Restore(*h.at(k).keyframe); err != nil {
+			return wator.Snapshot{}, err
+		}
+		h.replayAt = k
+		h.rebuilt = *h.at(k).keyframe
+	}
+	if h.replayAt < h.cursor {
+		for ; h.replayAt < h.cursor; h.replayAt++ {
+			h.replay.Update()
+		}
+		h.rebuilt = h.replay.Snapshot()
+	}
+	return h.rebuilt, nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"time"
+
+	"lazyhacker.dev/wa-tor/internal/chart"
+	"lazyhacker.dev/wa-tor/internal/wator"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	hudSamples = 300 // chronons the charts of the HUD show
+	hudWidth   = 260 // width of the HUD in the top right corner
+	hudTextH   = 68  // height of the text of the HUD
+	hudChartH  = 90  // height of the population chart
+	hudPhaseH  = 140 // height of the phase plot
+	hudMargin  = 8
+)
+
+// hud is the heads-up display of the populations in the top right corner.
+// It keeps the statistics of the latest chronons shown in a ring buffer.
+type hud struct {
+	hidden   bool // the statistics are not shown
+	noChart  bool // the population chart is not shown
+	phase    bool // the phase plot is shown
+	samples  []wator.Stats
+	next     int       // Index of samples the next chronon goes to.
+	count    int       // Number of samples kept.
+	chronons int       // Chronons counted since the rate was measured.
+	since    time.Time // Start of the measure of the rate.
+	rate     float64   // Chronons per second that were shown.
+}
+
+// reset forgets the chronons of the charts.
+func (h *hud) reset() {
+
+	h.samples = make([]wator.Stats, hudSamples)
+	h.next, h.count = 0, 0
+}
+
+// add keeps the statistics of a chronon.
+func (h *hud) add(s wator.Stats) {
+
+	if len(h.samples) == 0 {
+		h.reset()
+	}
+	h.samples[h.next] = s
+	h.next = (h.next + 1) % len(h.samples)
+	h.count = min(h.count+1, len(h.samples))
+	h.chronons++
+}
+
+// series returns the statistics kept from the oldest to the latest.
+func (h *hud) series() []wator.Stats {
+
+	s := make([]wator.Stats, 0, h.count)
+	for i := h.count; i > 0; i-- {
+		s = append(s, h.samples[(h.next-i+len(h.samples))%len(h.samples)])
+	}
+	return s
+}
+
+// measure updates the rate of the chronons shown about every second.
+func (h *hud) measure(now time.Time) {
+
+	if h.since.IsZero() {
+		h.since = now
+	}
+	if elapsed := now.Sub(h.since); elapsed >= time.Second {
+		h.rate = float64(h.chronons) / elapsed.Seconds()
+		h.chronons = 0
+		h.since = now
+	}
+}
+
+// hudKeys toggles the statistics with I, the population chart with G and the
+// phase plot with O.
+func (g *Game) hudKeys() {
+
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyI):
+		g.hud.hidden = !g.hud.hidden
+	case inpututil.IsKeyJustPressed(ebiten.KeyG):
+		g.hud.noChart = !g.hud.noChart
+	case inpututil.IsKeyJustPressed(ebiten.KeyO):
+		g.hud.phase = !g.hud.phase
+	}
+}
+
+// statsOf returns the statistics of a chronon played back, which only has
+// the state and the changes leading to it.
+func statsOf(chronon uint, state wator.WorldState, changes []wator.Delta) wator.Stats {
+
+	s := wator.Stats{Chronon: chronon}
+	for _, t := range state {
+		switch t {
+		case wator.FISH:
+			s.Fish++
+		case wator.SHARK:
+			s.Sharks++
+		}
+	}
+	for _, d := range changes {
+		switch {
+		case d.Action == wator.BIRTH && d.Object == wator.FISH:
+			s.FishBorn++
+		case d.Action == wator.BIRTH && d.Object == wator.SHARK:
+			s.SharksBorn++
+		case d.Action == wator.ATE:
+			s.FishEaten++
+		case d.Action == wator.DEATH && d.Object == wator.SHARK:
+			s.SharksStarved++
+		}
+	}
+	if len(state) > 0 {
+		s.Occupancy = float64(s.Fish+s.Sharks) / float64(len(state))
+	}
+	return s
+}
+
+// countChronon counts a new chronon in the overlays, the trails and the
+// HUD.
+func (g *Game) countChronon(s wator.Stats, state wator.WorldState, changes []wator.Delta) {
+
+	g.heat.add(state, changes)
+	g.trails.add(changes)
+	g.hud.add(s)
+}
+
+// resetCounts starts the overlays, the trails and the HUD over from the
+// shown chronon.
+func (g *Game) resetCounts() {
+
+	g.heat.reset(*heatWindow, g.world.Width*g.world.Height)
+	g.trails.reset(g.shownState(), *trailLength)
+	g.hud.reset()
+	g.hud.add(g.shownStats())
+}
+
+// shownStats returns the statistics of the chronon on the screen.
+func (g *Game) shownStats() wator.Stats {
+
+	switch {
+	case g.player != nil:
+		return statsOf(g.player.Chronon(), g.player.State(), nil)
+	case g.history != nil && g.history.rewound():
+		return g.history.current().stats
+	}
+	return g.world.Stats()
+}
+
+// DrawHUD draws the statistics of the shown chronon, the chart of the
+// latest populations and the phase plot in the top right corner.
+func (g *Game) DrawHUD(screen *ebiten.Image) {
+
+	h := &g.hud
+	h.measure(time.Now())
+	x := float32(g.screenW - hudWidth - hudMargin)
+	y := float32(hudMargin)
+
+	if !h.hidden {
+		// Stats of the shown chronon, which is the latest sample unless
+		// the game stepped back or seeked.
+		s := g.shownStats()
+		if h.count > 0 && g.player != nil {
+			if last := h.samples[(h.next-1+len(h.samples))%len(h.samples)]; last.Chronon == s.Chronon {
+				s = last
+			}
+		}
+		vector.DrawFilledRect(screen, x, y, hudWidth, hudTextH, color.RGBA{0, 0, 0, 140}, false)
+		msg := fmt.Sprintf("Fish %d  Sharks %d\nBorn: %d fish, %d sharks\nDied: %d fish eaten, %d sharks starved\n%.1f chronons/s  FPS %.0f  TPS %.0f",
+			s.Fish, s.Sharks, s.FishBorn, s.SharksBorn, s.FishEaten, s.SharksStarved, h.rate, ebiten.ActualFPS(), ebiten.ActualTPS())
+		ebitenutil.DebugPrintAt(screen, msg, int(x)+4, int(y))
+		y += hudTextH + hudMargin
+	}
+
+	series := h.series()
+	if !h.noChart && len(series) > 1 {
+		drawPopulationChart(screen, series, x, y, hudWidth, hudChartH)
+		y += hudChartH + hudMargin
+	}
+	if h.phase && len(series) > 1 {
+		drawPhasePlot(screen, series, x+hudWidth-hudPhaseH, y, hudPhaseH, hudPhaseH)
+	}
+}
+
+// drawPopulationChart draws the populations of the samples as lines in the
+// w by h area at x, y scaled to the largest population.
+func drawPopulationChart(screen *ebiten.Image, series []wator.Stats, x, y, w, h float32) {
+
+	vector.DrawFilledRect(screen, x, y, w, h, color.RGBA{255, 255, 255, 170}, false)
+	most := 1
+	for _, s := range series {
+		most = max(most, s.Fish, s.Sharks)
+	}
+
+	px := func(i int) float32 { return x + w*float32(i)/float32(hudSamples-1) }
+	py := func(n int) float32 { return y + h - 2 - (h-4)*float32(n)/float32(most) }
+	for i := 1; i < len(series); i++ {
+		a, b := series[i-1], series[i]
+		vector.StrokeLine(screen, px(i-1), py(a.Fish), px(i), py(b.Fish), 1.5, chart.FishColor, true)
+		vector.StrokeLine(screen, px(i-1), py(a.Sharks), px(i), py(b.Sharks), 1.5, chart.SharkColor, true)
+	}
+	ebitenutil.DebugPrintAt(screen, fmt.Sprint(most), int(x)+2, int(y))
+}
+
+// drawPhasePlot draws the sharks against the fish of the samples in the w by
+// h area at x, y with a dot on the latest chronon.
+func drawPhasePlot(screen *ebiten.Image, series []wator.Stats, x, y, w, h float32) {
+
+	vector.DrawFilledRect(screen, x, y, w, h, color.RGBA{255, 255, 255, 170}, false)
+	fish, sharks := 1, 1
+	for _, s := range series {
+		fish, sharks = max(fish, s.Fish), max(sharks, s.Sharks)
+	}
+
+	px := func(s wator.Stats) float32 { return x + 2 + (w-4)*float32(s.Fish)/float32(fish) }
+	py := func(s wator.Stats) float32 { return y + h - 2 - (h-4)*float32(s.Sharks)/float32(sharks) }
+	for i := 1; i < len(series); i++ {
+		a, b := series[i-1], series[i]
+		vector.StrokeLine(screen, px(a), py(a), px(b), py(b), 1, chart.SharkColor, true)
+	}
+	last := series[len(series)-1]
+	vector.DrawFilledCircle(screen, px(last), py(last), 3, chart.FishColor, true)
+	ebitenutil.DebugPrintAt(screen, "sharks/fish", int(x)+2, int(y))
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+	"time"
+
+	"lazyhacker.dev/wa-tor/internal/wator"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	inspectorW  = 220 // width of the box describing the creature
+	clickSlop   = 4   // pixels the cursor can move for a press to still be a click
+	inspectorLH = 16  // height of a line of the box
+)
+
+// inspector describes the creature under the cursor or the one that was
+// clicked, and can lock the camera on the clicked one.
+type inspector struct {
+	selected bool   // a creature was clicked
+	id       uint64 // id of the clicked creature, 0 during playback
+	pos      int    // tile the clicked creature was last seen on
+	species  int
+	follow   bool // the camera stays centred on the clicked creature
+	pressX   int  // cursor position when the left button was pressed
+	pressY   int
+}
+
+var speciesNames = map[int]string{wator.FISH: "Fish", wator.SHARK: "Shark"}
+
+// shownCreature returns the creature of the shown chronon at the tile.
+// Replays only have the species of the creatures.
+func (g *Game) shownCreature(pos int) (wator.Creature, bool) {
+
+	switch {
+	case g.player != nil:
+		state := g.player.State()
+		if pos < 0 || pos >= len(state) || state[pos] == wator.NONE {
+			return wator.Creature{}, false
+		}
+		return wator.Creature{Position: pos, Species: state[pos]}, true
+	case g.history != nil && g.history.rewound():
+		snap, err := g.history.snapshot()
+		if err != nil {
+			return wator.Creature{}, false
+		}
+		return snap.Creature(pos)
+	}
+	return g.world.Creature(pos)
+}
+
+// shownParams returns the parameters of the shown chronon.
+func (g *Game) shownParams() wator.Params {
+
+	if g.player == nil && g.history != nil && g.history.rewound() {
+		if snap, err := g.history.snapshot(); err == nil {
+			return snap.Params
+		}
+	}
+	return g.world.Params()
+}
+
+// locate returns the clicked creature in the shown chronon.  It returns false
+// if the creature died or wasn't born yet.
+func (g *Game) locate() (wator.Creature, bool) {
+
+	in := &g.inspect
+	if c, ok := g.shownCreature(in.pos); ok && c.ID == in.id && c.Species == in.species {
+		return c, true
+	}
+	if in.id == 0 {
+		return wator.Creature{}, false
+	}
+
+	// The creature moved since it was last seen.
+	pos, ok := -1, false
+	if g.player == nil && g.history != nil && g.history.rewound() {
+		snap, _ := g.history.snapshot()
+		for _, c := range snap.Creatures {
+			if c.ID == in.id {
+				pos, ok = c.Position, true
+				break
+			}
+		}
+	} else {
+		pos, ok = g.world.Find(in.id)
+	}
+	if !ok {
+		return wator.Creature{}, false
+	}
+	in.pos = pos
+	return g.shownCreature(pos)
+}
+
+// fate describes what happened to the creature that was at the tile during
+// the changes.
+func fate(changes []wator.Delta, pos int) string {
+
+	for _, d := range changes {
+		switch {
+		case d.Action == wator.ATE && d.To == pos:
+			return "was eaten"
+		case d.Action == wator.DEATH && d.From == pos:
+			return "starved"
+		}
+	}
+	return "is gone"
+}
+
+// updateInspector selects the creature clicked on, C follows it with the
+// camera and ESC forgets it.  Following stops when the creature is gone.
+func (g *Game) updateInspector() {
+
+	in := &g.inspect
+	x, y := ebiten.CursorPosition()
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		in.pressX, in.pressY = x, y
+	}
+	if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) && g.mouseFree() &&
+		abs(x-in.pressX) <= clickSlop && abs(y-in.pressY) <= clickSlop {
+		g.selectCreature()
+	}
+
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		in.selected, in.follow = false, false
+	case inpututil.IsKeyJustPressed(ebiten.KeyC):
+		switch {
+		case !in.selected:
+			g.status = "Click a creature to follow it"
+		case in.id == 0:
+			g.status = "Replays don't tell the creatures apart to follow one"
+		default:
+			in.follow = !in.follow
+		}
+	}
+
+	if in.selected && in.id != 0 {
+		if _, ok := g.locate(); !ok {
+			g.status = fmt.Sprintf("%s #%d %s", speciesNames[in.species], in.id, fate(g.anim.changes, in.pos))
+			in.selected, in.follow = false, false
+		}
+	}
+}
+
+// selectCreature selects the creature under the cursor, or forgets the
+// selected one when there is none.
+func (g *Game) selectCreature() {
+
+	in := &g.inspect
+	tile, inside := g.cursorTile()
+	c, ok := g.shownCreature(tile)
+	if !inside || !ok {
+		in.selected, in.follow = false, false
+		return
+	}
+	in.selected, in.id, in.pos, in.species = true, c.ID, c.Position, c.Species
+}
+
+// moveOffset returns where the creature arriving at the tile is drawn part
+// way through the changes, relative to the tile, in world pixels.
+func moveOffset(changes []wator.Delta, pos int, progress float64) (float64, float64) {
+
+	if progress >= 1 {
+		return 0, 0
+	}
+	// A shark moves onto the tile after the fish it eats, so the last move
+	// there is the creature on it.
+	left := (1 - max(progress, 0)) * TileSize
+	for i := len(changes) - 1; i >= 0; i-- {
+		if changes[i].To != pos {
+			continue
+		}
+		switch changes[i].Action {
+		case wator.MOVE_EAST:
+			return -left, 0
+		case wator.MOVE_WEST:
+			return left, 0
+		case wator.MOVE_NORTH:
+			return 0, left
+		case wator.MOVE_SOUTH:
+			return 0, -left
+		case wator.MOVE_NONE:
+			return 0, 0
+		}
+	}
+	return 0, 0
+}
+
+// drawnAt returns the world pixel the creature on the tile is drawn at.
+func (g *Game) drawnAt(pos int) (float64, float64) {
+
+	x, y := g.TileCoordinate(pos)
+	dx, dy := moveOffset(g.anim.changes, pos, g.anim.progress(time.Now()))
+	return x + dx, y + dy
+}
+
+// centerOn moves the camera so that the world pixel is in the middle of the
+// screen.
+func (g *Game) centerOn(x, y float64) {
+
+	g.cam.x = x - float64(g.screenW)/2/g.cam.zoom
+	g.cam.y = y - float64(g.screenH)/2/g.cam.zoom
+	g.cam.fit = false
+	g.wrapCamera()
+}
+
+// followCreature keeps the camera centred on the followed creature as it is
+// drawn moving.
+func (g *Game) followCreature() {
+
+	if !g.inspect.follow || g.cam.zoom == 0 {
+		return
+	}
+	if _, ok := g.locate(); ok {
+		x, y := g.drawnAt(g.inspect.pos)
+		g.centerOn(x+TileSize/2, y+TileSize/2)
+	}
+}
+
+// creatureInfo describes the creature with the parameters of its world.  A
+// creature without an id comes from a replay, which only has its species.
+func creatureInfo(c wator.Creature, p wator.Params) string {
+
+	if c.ID == 0 {
+		return speciesNames[c.Species] + "\nReplays only have the species."
+	}
+	lines := []string{
+		fmt.Sprintf("%s #%d", speciesNames[c.Species], c.ID),
+		fmt.Sprintf("Age %d chronons", c.Age),
+	}
+	if c.Species == wator.SHARK {
+		lines = append(lines, fmt.Sprintf("Health %d of %d", c.Health, p.Health))
+	}
+	if n := p.NextSpawn(c); n == 1 {
+		lines = append(lines, "Spawns next chronon")
+	} else {
+		lines = append(lines, fmt.Sprintf("Spawns in %d chronons", n))
+	}
+	if c.Parent == 0 {
+		lines = append(lines, "No parent, generation 0")
+	} else {
+		lines = append(lines, fmt.Sprintf("Parent #%d, generation %d", c.Parent, c.Generation))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DrawInspector outlines the clicked creature and the one under the cursor
+// and describes the clicked one, or else the hovered one, in the bottom left
+// corner.
+func (g *Game) DrawInspector(screen *ebiten.Image) {
+
+	in := &g.inspect
+	outline := func(pos int, clr color.Color) {
+		x, y := g.drawnAt(pos)
+		g.onScreen(x, y, TileSize, TileSize, func(sx, sy float64) {
+			size := float32(TileSize * g.cam.zoom)
+			vector.StrokeRect(screen, float32(sx), float32(sy), size, size, 2, clr, false)
+		})
+	}
+
+	var shown wator.Creature
+	ok := false
+	if tile, inside := g.cursorTile(); inside && g.mouseFree() {
+		if shown, ok = g.shownCreature(tile); ok {
+			outline(tile, color.White)
+		}
+	}
+	if in.selected {
+		if c, found := g.locate(); found {
+			shown, ok = c, true
+			outline(c.Position, color.RGBA{255, 220, 80, 255})
+		}
+	}
+	if !ok {
+		return
+	}
+
+	msg := creatureInfo(shown, g.shownParams())
+	if in.selected && in.follow && shown.ID == in.id {
+		msg += "\nFollowing, C to stop"
+	} else if in.selected && shown.ID == in.id {
+		msg += "\nC to follow, ESC to forget"
+	}
+	h := float32(inspectorLH * (strings.Count(msg, "\n") + 1))
+	y := float32(g.screenH) - h - hudMargin
+	if g.player != nil {
+		y -= ScrubberHeight
+	}
+	vector.DrawFilledRect(screen, hudMargin, y, inspectorW, h, color.RGBA{0, 0, 0, 140}, false)
+	ebitenutil.DebugPrintAt(screen, msg, hudMargin+4, int(y))
+}
+
+// abs returns the absolute value of n.
+func abs(n int) int {
+
+	if n < 0 {
+		return -n
+	}
+	return n
+}
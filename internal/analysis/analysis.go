@@ -0,0 +1,78 @@
+// Package analysis studies the population time series produced by the wa-tor
+// simulation.
+package analysis
+
+// Mean returns the average of the series or 0 if it is empty.
+func Mean(series []float64) float64 {
+
+	if len(series) == 0 {
+		return 0
+	}
+	var total float64
+	for _, v := range series {
+		total += v
+	}
+	return total / float64(len(series))
+}
+
+// Autocorrelation returns the normalized autocorrelation of the series for
+// lags 0 to maxLag.  A constant series has no correlation and returns all 0.
+func Autocorrelation(series []float64, maxLag int) []float64 {
+
+	n := len(series)
+	if maxLag >= n {
+		maxLag = n - 1
+	}
+	if maxLag < 0 {
+		return nil
+	}
+
+	m := Mean(series)
+	var variance float64
+	for _, v := range series {
+		variance += (v - m) * (v - m)
+	}
+
+	acf := make([]float64, maxLag+1)
+	if variance == 0 {
+		return acf
+	}
+	for lag := 0; lag <= maxLag; lag++ {
+		var sum float64
+		for i := 0; i+lag < n; i++ {
+			sum += (series[i] - m) * (series[i+lag] - m)
+		}
+		acf[lag] = sum / variance
+	}
+
+	return acf
+}
+
+// Period returns the dominant period of the series in samples using its
+// autocorrelation.  The period is the lag with the highest correlation after
+// the correlation first turns negative.  It returns 0 if the series doesn't
+// oscillate.
+func Period(series []float64) int {
+
+	acf := Autocorrelation(series, len(series)/2)
+
+	start := 0
+	for lag := 1; lag < len(acf); lag++ {
+		if acf[lag] < 0 {
+			start = lag
+			break
+		}
+	}
+	if start == 0 {
+		return 0
+	}
+
+	best := 0
+	for lag := start; lag < len(acf); lag++ {
+		if acf[lag] > 0 && (best == 0 || acf[lag] > acf[best]) {
+			best = lag
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,70 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+// sine returns n samples of a sine wave with the given period.
+func sine(n int, period, amplitude, offset float64) []float64 {
+	s := make([]float64, n)
+	for i := range s {
+		s[i] = offset + amplitude*math.Sin(2*math.Pi*float64(i)/period)
+	}
+	return s
+}
+
+func TestMean(t *testing.T) {
+	tests := []struct {
+		series []float64
+		want   float64
+	}{
+		{nil, 0},
+		{[]float64{1, 2, 3, 4}, 2.5},
+	}
+	for _, tc := range tests {
+		if got := Mean(tc.series); got != tc.want {
+			t.Errorf("Mean(%v) = %v, expected %v", tc.series, got, tc.want)
+		}
+	}
+}
+
+func TestAutocorrelation(t *testing.T) {
+	acf := Autocorrelation(sine(200, 20, 1, 5), 20)
+	if len(acf) != 21 {
+		t.Fatalf("Expected 21 lags, got %d", len(acf))
+	}
+	if math.Abs(acf[0]-1) > 1e-9 {
+		t.Errorf("Expected lag 0 to be 1, got %v", acf[0])
+	}
+	if acf[10] > -0.8 {
+		t.Errorf("Expected strong negative correlation at half a period, got %v", acf[10])
+	}
+
+	for _, v := range Autocorrelation([]float64{3, 3, 3, 3}, 2) {
+		if v != 0 {
+			t.Errorf("Expected no correlation for a constant series, got %v", v)
+		}
+	}
+}
+
+func TestPeriod(t *testing.T) {
+	tests := []struct {
+		name   string
+		series []float64
+		want   int
+	}{
+		{"period 25", sine(500, 25, 100, 300), 25},
+		{"period 60", sine(600, 60, 10, 50), 60},
+		{"constant", []float64{4, 4, 4, 4, 4, 4}, 0},
+		{"increasing", []float64{1, 2, 3, 4, 5, 6, 7, 8}, 0},
+		{"empty", nil, 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Period(tc.series); got != tc.want {
+				t.Errorf("Period() = %d, expected %d", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package analysis
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// Oscillation describes the cycles of one population.  Periods and rates are
+// per sample, except in a Report where they are per chronon.
+type Oscillation struct {
+	Mean        float64 `json:"mean"`        // Average population.
+	ACFPeriod   int     `json:"acf_period"`  // Dominant period from the autocorrelation, 0 if none.
+	FFTPeriod   float64 `json:"fft_period"`  // Dominant period from the spectrum, 0 if none.
+	Amplitude   float64 `json:"amplitude"`   // Amplitude of the oscillation around the trend.
+	GrowthRate  float64 `json:"growth_rate"` // Change of the amplitude per sample as a fraction.
+	Periodicity float64 `json:"periodicity"` // Autocorrelation at the ACF period, 1 is perfectly periodic.
+}
+
+// Trend describes the growth rate as damped, steady or growing.  Rates
+// within the tolerance either way are steady.
+func (o Oscillation) Trend(tolerance float64) string {
+
+	switch {
+	case o.GrowthRate < -tolerance:
+		return "damped"
+	case o.GrowthRate > tolerance:
+		return "growing"
+	}
+	return "steady"
+}
+
+// Oscillate analyses the cycles of a population series.
+func Oscillate(series []float64) Oscillation {
+
+	o := Oscillation{
+		Mean:      Mean(series),
+		ACFPeriod: Period(series),
+		FFTPeriod: SpectralPeriod(series),
+		Amplitude: Amplitude(series),
+	}
+
+	if o.ACFPeriod > 0 {
+		o.Periodicity = Autocorrelation(series, o.ACFPeriod)[o.ACFPeriod]
+		o.GrowthRate = GrowthRate(series, o.ACFPeriod)
+	}
+
+	return o
+}
+
+// Amplitude returns the amplitude of the oscillation of the series around its
+// linear trend.  It is estimated from the standard deviation as √2·σ, which is
+// exact for a sine wave.
+func Amplitude(series []float64) float64 {
+
+	residual := Detrend(series)
+	if len(residual) == 0 {
+		return 0
+	}
+	var sq float64
+	for _, v := range residual {
+		sq += v * v
+	}
+	return math.Sqrt(2 * sq / float64(len(residual)))
+}
+
+// Detrend returns the series with its least squares line removed.
+func Detrend(series []float64) []float64 {
+
+	slope, intercept := LinearFit(series)
+	residual := make([]float64, len(series))
+	for i, v := range series {
+		residual[i] = v - (intercept + slope*float64(i))
+	}
+	return residual
+}
+
+// LinearFit returns the least squares line through the series where x is the
+// index of the sample.
+func LinearFit(series []float64) (slope, intercept float64) {
+
+	n := float64(len(series))
+	if n == 0 {
+		return 0, 0
+	}
+	if n == 1 {
+		return 0, series[0]
+	}
+
+	mx := (n - 1) / 2
+	my := Mean(series)
+	var sxy, sxx float64
+	for i, v := range series {
+		dx := float64(i) - mx
+		sxy += dx * (v - my)
+		sxx += dx * dx
+	}
+	slope = sxy / sxx
+	return slope, my - slope*mx
+}
+
+// GrowthRate returns how fast the oscillation grows (positive) or is damped
+// (negative) as a fraction of its amplitude per sample.  The amplitude is
+// measured for every complete period and an exponential is fitted to it.  It
+// returns 0 if there are fewer than 2 periods.
+func GrowthRate(series []float64, period int) float64 {
+
+	if period <= 0 || len(series) < 2*period {
+		return 0
+	}
+
+	var logs []float64
+	for start := 0; start+period <= len(series); start += period {
+		a := Amplitude(series[start : start+period])
+		if a <= 0 {
+			return 0
+		}
+		logs = append(logs, math.Log(a))
+	}
+
+	slope, _ := LinearFit(logs)
+	return slope / float64(period)
+}
+
+// SpectralPeriod returns the dominant period of the series in samples as the
+// peak of its power spectrum.  The series is detrended and zero padded to a
+// power of 2.  It returns 0 if there is no peak, such as for a constant
+// series, or if the peak is the slowest frequency, which cannot be told
+// apart from a trend.
+func SpectralPeriod(series []float64) float64 {
+
+	if len(series) < 4 {
+		return 0
+	}
+
+	residual := Detrend(series)
+	n := 1
+	for n < len(residual) {
+		n *= 2
+	}
+	// A Hann window reduces the leakage from the ends of the series.
+	data := make([]complex128, n)
+	for i, v := range residual {
+		w := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(len(residual)-1))
+		data[i] = complex(v*w, 0)
+	}
+	FFT(data)
+
+	best := 0
+	var bestPower float64
+	for k := 1; k < n/2; k++ {
+		if p := cmplx.Abs(data[k]); p > bestPower {
+			best, bestPower = k, p
+		}
+	}
+	if best <= 1 || bestPower < 1e-9 {
+		return 0
+	}
+
+	// Refine the peak between frequency bins with a parabola.
+	offset := 0.0
+	a, b, c := cmplx.Abs(data[best-1]), bestPower, cmplx.Abs(data[best+1])
+	if d := a - 2*b + c; d != 0 {
+		offset = 0.5 * (a - c) / d
+	}
+
+	return float64(n) / (float64(best) + offset)
+}
+
+// FFT computes the discrete Fourier transform of the data in place.  The
+// length of the data must be a power of 2.
+func FFT(data []complex128) {
+
+	n := len(data)
+
+	// Bit reversal permutation.
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			data[i], data[j] = data[j], data[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even, odd := data[start+k], data[start+k+size/2]*w
+				data[start+k] = even + odd
+				data[start+k+size/2] = even - odd
+				w *= step
+			}
+		}
+	}
+}
+
+// CrossCorrelation returns the normalized correlation of b shifted by lag
+// samples against a, for lags -maxLag to maxLag.  Index maxLag is lag 0.
+func CrossCorrelation(a, b []float64, maxLag int) []float64 {
+
+	n := min(len(a), len(b))
+	if maxLag >= n {
+		maxLag = n - 1
+	}
+	if maxLag < 0 {
+		return nil
+	}
+
+	ma, mb := Mean(a[:n]), Mean(b[:n])
+	var va, vb float64
+	for i := 0; i < n; i++ {
+		va += (a[i] - ma) * (a[i] - ma)
+		vb += (b[i] - mb) * (b[i] - mb)
+	}
+
+	cc := make([]float64, 2*maxLag+1)
+	if va == 0 || vb == 0 {
+		return cc
+	}
+	norm := math.Sqrt(va * vb)
+	for lag := -maxLag; lag <= maxLag; lag++ {
+		var sum float64
+		for i := 0; i < n; i++ {
+			if j := i + lag; j >= 0 && j < n {
+				sum += (a[i] - ma) * (b[j] - mb)
+			}
+		}
+		cc[lag+maxLag] = sum / norm
+	}
+
+	return cc
+}
+
+// PhaseLag returns by how many samples b follows a, looking at lags up to
+// maxLag either way.  A negative lag means b leads a.
+func PhaseLag(a, b []float64, maxLag int) int {
+
+	cc := CrossCorrelation(a, b, maxLag)
+	if len(cc) == 0 {
+		return 0
+	}
+
+	best := len(cc) / 2
+	for i, v := range cc {
+		if v > cc[best] {
+			best = i
+		}
+	}
+	return best - len(cc)/2
+}
@@ -0,0 +1,138 @@
+package analysis
+
+import (
+	"math"
+	"math/cmplx"
+	"strings"
+	"testing"
+)
+
+func TestFFT(t *testing.T) {
+	// A cosine with 2 cycles over 8 samples has its energy in bins 2 and 6.
+	data := make([]complex128, 8)
+	for i := range data {
+		data[i] = complex(math.Cos(2*math.Pi*2*float64(i)/8), 0)
+	}
+	FFT(data)
+	for k, v := range data {
+		want := 0.0
+		if k == 2 || k == 6 {
+			want = 4
+		}
+		if math.Abs(cmplx.Abs(v)-want) > 1e-9 {
+			t.Errorf("Bin %d: expected magnitude %v, got %v", k, want, cmplx.Abs(v))
+		}
+	}
+}
+
+func TestSpectralPeriod(t *testing.T) {
+	tests := []struct {
+		name   string
+		series []float64
+		want   float64
+	}{
+		{"period 25", sine(500, 25, 100, 300), 25},
+		{"period 64", sine(1000, 64, 10, 50), 64},
+		{"constant", []float64{2, 2, 2, 2, 2, 2, 2, 2}, 0},
+		{"too short", []float64{1, 2}, 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := SpectralPeriod(tc.series)
+			if math.Abs(got-tc.want) > tc.want*0.05 {
+				t.Errorf("SpectralPeriod() = %v, expected %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLinearFit(t *testing.T) {
+	slope, intercept := LinearFit([]float64{1, 3, 5, 7})
+	if slope != 2 || intercept != 1 {
+		t.Errorf("LinearFit() = %v, %v, expected 2, 1", slope, intercept)
+	}
+	if d := Detrend([]float64{1, 3, 5, 7}); math.Abs(d[0])+math.Abs(d[3]) > 1e-9 {
+		t.Errorf("Expected a line to detrend to 0, got %v", d)
+	}
+}
+
+func TestAmplitude(t *testing.T) {
+	// The amplitude ignores the offset and a linear trend.
+	s := sine(1000, 50, 30, 200)
+	for i := range s {
+		s[i] += 0.5 * float64(i)
+	}
+	if got := Amplitude(s); math.Abs(got-30) > 0.5 {
+		t.Errorf("Amplitude() = %v, expected 30", got)
+	}
+}
+
+func TestGrowthRate(t *testing.T) {
+	tests := []struct {
+		name string
+		rate float64
+	}{
+		{"damped", -0.002},
+		{"steady", 0},
+		{"growing", 0.003},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := make([]float64, 2000)
+			for i := range s {
+				s[i] = 100 + 20*math.Exp(tc.rate*float64(i))*math.Sin(2*math.Pi*float64(i)/40)
+			}
+			got := GrowthRate(s, 40)
+			if math.Abs(got-tc.rate) > 2e-4 {
+				t.Errorf("GrowthRate() = %v, expected %v", got, tc.rate)
+			}
+			o := Oscillation{GrowthRate: got}
+			if trend := o.Trend(GrowthTolerance); trend != tc.name {
+				t.Errorf("Trend() = %q, expected %q", trend, tc.name)
+			}
+		})
+	}
+	if got := GrowthRate(sine(50, 40, 1, 0), 40); got != 0 {
+		t.Errorf("Expected 0 with less than 2 periods, got %v", got)
+	}
+}
+
+func TestPhaseLag(t *testing.T) {
+	prey := sine(600, 60, 100, 500)
+	predators := make([]float64, len(prey))
+	for i := range predators {
+		predators[i] = 50 + 10*math.Sin(2*math.Pi*float64(i-12)/60)
+	}
+	if got := PhaseLag(prey, predators, 30); got != 12 {
+		t.Errorf("PhaseLag() = %d, expected 12", got)
+	}
+	if got := PhaseLag(predators, prey, 30); got != -12 {
+		t.Errorf("PhaseLag() reversed = %d, expected -12", got)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	fish := make([]float64, 400)
+	sharks := make([]float64, 400)
+	for i := range fish {
+		fish[i] = 300 + 100*math.Sin(2*math.Pi*float64(i)/40)
+		sharks[i] = 60 + 20*math.Sin(2*math.Pi*float64(i-10)/40)
+	}
+
+	// Sampled every 5 chronons.
+	r := Analyze(fish, sharks, 5)
+	if r.Fish.ACFPeriod != 200 || r.Sharks.ACFPeriod != 200 {
+		t.Errorf("Expected periods of 200 chronons, got %d and %d", r.Fish.ACFPeriod, r.Sharks.ACFPeriod)
+	}
+	if r.Lag != 50 || r.LagPhase != 0.25 {
+		t.Errorf("Expected a lag of 50 chronons (0.25), got %v (%v)", r.Lag, r.LagPhase)
+	}
+
+	var out strings.Builder
+	if err := r.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Sharks lag fish by 50 chronons (0.25 of a period)") {
+		t.Errorf("Unexpected report:\n%s", out.String())
+	}
+}
@@ -0,0 +1,92 @@
+package analysis
+
+import (
+	"fmt"
+	"io"
+)
+
+// GrowthTolerance is the growth rate per chronon below which the oscillation
+// in a report is considered steady.
+const GrowthTolerance = 1e-4
+
+// Report is the oscillation analysis of the fish and shark populations of a
+// run.  Periods and lags are in chronons.
+type Report struct {
+	Samples  int         `json:"samples"`  // Number of samples analysed.
+	Interval float64     `json:"interval"` // Chronons between samples.
+	Fish     Oscillation `json:"fish"`
+	Sharks   Oscillation `json:"sharks"`
+	Lag      float64     `json:"lag"`       // Chronons the sharks follow the fish by.
+	LagPhase float64     `json:"lag_phase"` // Lag as a fraction of the fish period.
+}
+
+// Analyze studies the predator-prey cycles of the fish and shark series
+// sampled every interval chronons.
+func Analyze(fish, sharks []float64, interval float64) Report {
+
+	if interval <= 0 {
+		interval = 1
+	}
+
+	r := Report{
+		Samples:  min(len(fish), len(sharks)),
+		Interval: interval,
+		Fish:     Oscillate(fish),
+		Sharks:   Oscillate(sharks),
+	}
+
+	// Only look for the lag within a period, otherwise any multiple of the
+	// period fits as well.
+	maxLag := r.Samples / 4
+	if r.Fish.ACFPeriod > 0 {
+		maxLag = r.Fish.ACFPeriod / 2
+	}
+	lag := PhaseLag(fish, sharks, maxLag)
+	if r.Fish.ACFPeriod > 0 {
+		r.LagPhase = float64(lag) / float64(r.Fish.ACFPeriod)
+	}
+
+	r.Lag = float64(lag) * interval
+	r.Fish = r.Fish.scale(interval)
+	r.Sharks = r.Sharks.scale(interval)
+
+	return r
+}
+
+// scale converts the periods and rates from samples to chronons.
+func (o Oscillation) scale(interval float64) Oscillation {
+
+	o.ACFPeriod = int(float64(o.ACFPeriod) * interval)
+	o.FFTPeriod *= interval
+	o.GrowthRate /= interval
+	return o
+}
+
+// Write prints the report in a human readable form.
+func (r Report) Write(out io.Writer) error {
+
+	species := []struct {
+		name string
+		o    Oscillation
+	}{{"Fish", r.Fish}, {"Sharks", r.Sharks}}
+
+	if _, err := fmt.Fprintf(out, "Samples: %d every %g chronons\n", r.Samples, r.Interval); err != nil {
+		return err
+	}
+	for _, s := range species {
+		_, err := fmt.Fprintf(out, "%s:\n"+
+			"  mean          %.1f\n"+
+			"  period (ACF)  %d chronons (correlation %.2f)\n"+
+			"  period (FFT)  %.1f chronons\n"+
+			"  amplitude     %.1f\n"+
+			"  trend         %s (%+.2e per chronon)\n",
+			s.name, s.o.Mean, s.o.ACFPeriod, s.o.Periodicity, s.o.FFTPeriod,
+			s.o.Amplitude, s.o.Trend(GrowthTolerance), s.o.GrowthRate)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(out, "Sharks lag fish by %g chronons (%.2f of a period)\n", r.Lag, r.LagPhase)
+
+	return err
+}
@@ -0,0 +1,279 @@
+// Package chart draws the population statistics of the wa-tor simulation as
+// line charts in PNG or SVG without needing a display.
+//
+// # Usage:
+//
+//	c := chart.Population(recorder.Samples)
+//	c.PNG(file)
+package chart
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"lazyhacker.dev/wa-tor/internal/wator"
+)
+
+// Default size of a chart in pixels.
+const (
+	DefaultWidth  = 800
+	DefaultHeight = 500
+)
+
+// Margins around the plot area in pixels leaving room for the title, the
+// axis labels and the tick labels.
+const (
+	marginLeft   = 70
+	marginRight  = 20
+	marginTop    = 40
+	marginBottom = 50
+	tickLength   = 5
+	charWidth    = 7 // width of a character of the font
+	charHeight   = 13
+)
+
+// Colors of the species, matching the sprites of the graphical version.
+var (
+	FishColor  = color.RGBA{230, 120, 20, 255}
+	SharkColor = color.RGBA{60, 70, 110, 255}
+	background = color.RGBA{255, 255, 255, 255}
+	foreground = color.RGBA{0, 0, 0, 255}
+	gridColor  = color.RGBA{220, 220, 220, 255}
+)
+
+// Series is a line of the chart.
+type Series struct {
+	Name  string
+	Color color.RGBA
+	X, Y  []float64
+}
+
+// Chart is a line chart with axes, tick labels and a legend.
+type Chart struct {
+	Title          string
+	XLabel, YLabel string
+	Width, Height  int
+	Series         []Series
+}
+
+// Population returns a chart of the number of fish and sharks over time.
+func Population(samples []wator.Stats) *Chart {
+
+	fish := Series{Name: "Fish", Color: FishColor}
+	sharks := Series{Name: "Sharks", Color: SharkColor}
+	for _, s := range samples {
+		fish.X = append(fish.X, float64(s.Chronon))
+		fish.Y = append(fish.Y, float64(s.Fish))
+		sharks.X = append(sharks.X, float64(s.Chronon))
+		sharks.Y = append(sharks.Y, float64(s.Sharks))
+	}
+
+	return &Chart{
+		Title:  "Population",
+		XLabel: "Chronon",
+		YLabel: "Count",
+		Width:  DefaultWidth,
+		Height: DefaultHeight,
+		Series: []Series{fish, sharks},
+	}
+}
+
+// Phase returns the phase portrait of the populations: the number of sharks
+// against the number of fish.  Predator-prey cycles show up as loops.
+func Phase(samples []wator.Stats) *Chart {
+
+	phase := Series{Name: "Sharks vs fish", Color: SharkColor}
+	for _, s := range samples {
+		phase.X = append(phase.X, float64(s.Fish))
+		phase.Y = append(phase.Y, float64(s.Sharks))
+	}
+
+	return &Chart{
+		Title:  "Phase portrait",
+		XLabel: "Fish",
+		YLabel: "Sharks",
+		Width:  DefaultHeight,
+		Height: DefaultHeight,
+		Series: []Series{phase},
+	}
+}
+
+// Write draws the chart in the format matching the extension of the file
+// name, .png or .svg.
+func (c *Chart) Write(out io.Writer, name string) error {
+
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".png":
+		return c.PNG(out)
+	case ".svg":
+		return c.SVG(out)
+	}
+	return fmt.Errorf("Unknown chart format %q, expected .png or .svg.", filepath.Ext(name))
+}
+
+// canvas is what a chart is drawn on.  Coordinates are in pixels from the
+// top left corner.
+type canvas interface {
+	line(x1, y1, x2, y2 float64, c color.RGBA, width float64)
+	polyline(xs, ys []float64, c color.RGBA)
+	rect(x, y, w, h float64, c color.RGBA)
+	text(x, y float64, s string, c color.RGBA) // y is the baseline
+}
+
+// axis maps a range of data values to pixels.
+type axis struct {
+	min, max   float64 // data range
+	start, end float64 // pixel range
+	ticks      []float64
+}
+
+// pixel returns the pixel position of a data value.
+func (a axis) pixel(v float64) float64 {
+	return a.start + (v-a.min)/(a.max-a.min)*(a.end-a.start)
+}
+
+// newAxis returns an axis covering the values with round tick marks.
+func newAxis(values [][]float64, start, end float64) axis {
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, vs := range values {
+		for _, v := range vs {
+			lo = math.Min(lo, v)
+			hi = math.Max(hi, v)
+		}
+	}
+	if math.IsInf(lo, 0) {
+		lo, hi = 0, 1
+	}
+	if hi == lo {
+		hi = lo + 1
+	}
+
+	step := niceStep((hi - lo) / 5)
+	a := axis{
+		min:   math.Floor(lo/step) * step,
+		max:   math.Ceil(hi/step) * step,
+		start: start,
+		end:   end,
+	}
+	for v := a.min; v <= a.max+step/2; v += step {
+		a.ticks = append(a.ticks, v)
+	}
+
+	return a
+}
+
+// niceStep rounds a tick spacing to 1, 2 or 5 times a power of 10.
+func niceStep(raw float64) float64 {
+
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	switch f := raw / magnitude; {
+	case f <= 1:
+		return magnitude
+	case f <= 2:
+		return 2 * magnitude
+	case f <= 5:
+		return 5 * magnitude
+	}
+	return 10 * magnitude
+}
+
+// formatTick prints a tick value without useless decimals.
+func formatTick(v float64) string {
+
+	if math.Abs(v) < 1e-9 {
+		v = 0
+	}
+	return strconv.FormatFloat(v, 'g', 6, 64)
+}
+
+// size returns the size of the chart, using the default for unset values.
+func (c *Chart) size() (float64, float64) {
+
+	w, h := c.Width, c.Height
+	if w <= 0 {
+		w = DefaultWidth
+	}
+	if h <= 0 {
+		h = DefaultHeight
+	}
+	return float64(w), float64(h)
+}
+
+// draw paints the chart on the canvas.
+func (c *Chart) draw(cv canvas) {
+
+	width, height := c.size()
+	cv.rect(0, 0, width, height, background)
+
+	var xs, ys [][]float64
+	for _, s := range c.Series {
+		xs = append(xs, s.X)
+		ys = append(ys, s.Y)
+	}
+	left, right := float64(marginLeft), width-marginRight
+	top, bottom := float64(marginTop), height-marginBottom
+	xAxis := newAxis(xs, left, right)
+	yAxis := newAxis(ys, bottom, top)
+
+	// Grid lines and tick labels.
+	for _, t := range xAxis.ticks {
+		x := xAxis.pixel(t)
+		cv.line(x, top, x, bottom, gridColor, 1)
+		cv.line(x, bottom, x, bottom+tickLength, foreground, 1)
+		label := formatTick(t)
+		cv.text(x-float64(len(label)*charWidth)/2, bottom+tickLength+charHeight, label, foreground)
+	}
+	for _, t := range yAxis.ticks {
+		y := yAxis.pixel(t)
+		cv.line(left, y, right, y, gridColor, 1)
+		cv.line(left-tickLength, y, left, y, foreground, 1)
+		label := formatTick(t)
+		cv.text(left-tickLength-2-float64(len(label)*charWidth), y+charHeight/2-2, label, foreground)
+	}
+
+	// Axes and labels.
+	cv.line(left, bottom, right, bottom, foreground, 1)
+	cv.line(left, top, left, bottom, foreground, 1)
+	cv.text((left+right-float64(len(c.XLabel)*charWidth))/2, height-10, c.XLabel, foreground)
+	cv.text(left-float64(len(c.YLabel)*charWidth)/2, top-8, c.YLabel, foreground)
+	cv.text((width-float64(len(c.Title)*charWidth))/2, 18, c.Title, foreground)
+
+	// Lines of the series.
+	for _, s := range c.Series {
+		n := min(len(s.X), len(s.Y))
+		px := make([]float64, n)
+		py := make([]float64, n)
+		for i := 0; i < n; i++ {
+			px[i] = xAxis.pixel(s.X[i])
+			py[i] = yAxis.pixel(s.Y[i])
+		}
+		cv.polyline(px, py, s.Color)
+	}
+
+	// Legend in the top right corner of the plot area.
+	if len(c.Series) > 1 {
+		longest := 0
+		for _, s := range c.Series {
+			longest = max(longest, len(s.Name))
+		}
+		boxWidth := float64(longest*charWidth + 40)
+		boxHeight := float64(len(c.Series)*(charHeight+5) + 8)
+		x, y := right-boxWidth-10, top+10
+		cv.rect(x, y, boxWidth, boxHeight, background)
+		cv.line(x, y, x+boxWidth, y, foreground, 1)
+		cv.line(x, y+boxHeight, x+boxWidth, y+boxHeight, foreground, 1)
+		cv.line(x, y, x, y+boxHeight, foreground, 1)
+		cv.line(x+boxWidth, y, x+boxWidth, y+boxHeight, foreground, 1)
+		for i, s := range c.Series {
+			ly := y + 4 + float64(i*(charHeight+5)) + charHeight
+			cv.line(x+8, ly-4, x+28, ly-4, s.Color, 2)
+			cv.text(x+34, ly, s.Name, foreground)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package chart
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+
+	"lazyhacker.dev/wa-tor/internal/wator"
+)
+
+// samples returns a short made up recording.
+func samples() []wator.Stats {
+	var s []wator.Stats
+	for c := 0; c <= 100; c++ {
+		s = append(s, wator.Stats{Chronon: uint(c), Fish: 200 + c%20*10, Sharks: 20 + (c+5)%20})
+	}
+	return s
+}
+
+func TestNiceStep(t *testing.T) {
+	tests := []struct {
+		raw, want float64
+	}{
+		{0.7, 1}, {1, 1}, {1.3, 2}, {3, 5}, {7, 10}, {130, 200}, {0.04, 0.05},
+	}
+	for _, tc := range tests {
+		if got := niceStep(tc.raw); got != tc.want {
+			t.Errorf("niceStep(%v) = %v, expected %v", tc.raw, got, tc.want)
+		}
+	}
+}
+
+func TestNewAxis(t *testing.T) {
+	a := newAxis([][]float64{{3, 47}, {12}}, 0, 100)
+	if a.min != 0 || a.max != 50 {
+		t.Errorf("Expected axis from 0 to 50, got %v to %v", a.min, a.max)
+	}
+	if len(a.ticks) != 6 || a.ticks[1] != 10 {
+		t.Errorf("Expected ticks every 10, got %v", a.ticks)
+	}
+	if p := a.pixel(25); p != 50 {
+		t.Errorf("Expected 25 at pixel 50, got %v", p)
+	}
+
+	// An empty or constant series still gets a usable axis.
+	for _, values := range [][][]float64{nil, {{5, 5}}} {
+		a := newAxis(values, 0, 100)
+		if a.max <= a.min || len(a.ticks) < 2 {
+			t.Errorf("newAxis(%v): unusable axis %+v", values, a)
+		}
+	}
+}
+
+func TestPNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Population(samples()).PNG(&buf); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("Unable to decode the chart: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != DefaultWidth || b.Dy() != DefaultHeight {
+		t.Errorf("Expected %dx%d image, got %v", DefaultWidth, DefaultHeight, b)
+	}
+
+	colors := map[string]bool{}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			switch {
+			case uint8(r>>8) == FishColor.R && uint8(g>>8) == FishColor.G && uint8(bl>>8) == FishColor.B:
+				colors["fish"] = true
+			case uint8(r>>8) == SharkColor.R && uint8(g>>8) == SharkColor.G && uint8(bl>>8) == SharkColor.B:
+				colors["sharks"] = true
+			}
+		}
+	}
+	if !colors["fish"] || !colors["sharks"] {
+		t.Errorf("Expected both populations to be drawn, found %v", colors)
+	}
+}
+
+func TestSVG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Population(samples()).SVG(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// The document must be well formed.
+	dec := xml.NewDecoder(bytes.NewReader(buf.Bytes()))
+	counts := map[string]int{}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Invalid SVG: %v", err)
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			counts[se.Name.Local]++
+		}
+	}
+	if counts["svg"] != 1 || counts["polyline"] != 2 {
+		t.Errorf("Expected 1 svg and 2 polylines, got %v", counts)
+	}
+	for _, want := range []string{">Fish</text>", ">Sharks</text>", ">Chronon</text>", ">Population</text>"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in the SVG", want)
+		}
+	}
+}
+
+func TestPhase(t *testing.T) {
+	c := Phase(samples())
+	if len(c.Series) != 1 || c.Series[0].X[3] != 230 || c.Series[0].Y[3] != 28 {
+		t.Errorf("Expected sharks against fish, got %+v", c.Series)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	c := Phase(samples())
+	for _, name := range []string{"a.png", "b.SVG"} {
+		if err := c.Write(io.Discard, name); err != nil {
+			t.Errorf("Write(%q): %v", name, err)
+		}
+	}
+	if err := c.Write(io.Discard, "c.jpg"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
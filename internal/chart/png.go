@@ -0,0 +1,110 @@
+package chart
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// raster draws the chart on an image.
+type raster struct {
+	img *image.RGBA
+}
+
+// Image returns the chart drawn on an image.
+func (c *Chart) Image() *image.RGBA {
+
+	w, h := c.size()
+	r := &raster{image.NewRGBA(image.Rect(0, 0, int(w), int(h)))}
+	c.draw(r)
+	return r.img
+}
+
+// PNG writes the chart as a PNG image.
+func (c *Chart) PNG(out io.Writer) error {
+	return png.Encode(out, c.Image())
+}
+
+func (r *raster) rect(x, y, w, h float64, c color.RGBA) {
+
+	rect := image.Rect(int(x), int(y), int(x+w), int(y+h))
+	draw.Draw(r.img, rect, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+func (r *raster) text(x, y float64, s string, c color.RGBA) {
+
+	d := font.Drawer{
+		Dst:  r.img,
+		Src:  image.NewUniform(c),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(int(x), int(y)),
+	}
+	d.DrawString(s)
+}
+
+func (r *raster) polyline(xs, ys []float64, c color.RGBA) {
+
+	for i := 1; i < len(xs); i++ {
+		r.line(xs[i-1], ys[i-1], xs[i], ys[i], c, 2)
+	}
+}
+
+// line draws a line with Bresenham's algorithm.  Wider lines are drawn as
+// several lines next to each other.
+func (r *raster) line(x1, y1, x2, y2 float64, c color.RGBA, width float64) {
+
+	// Offset the copies across the direction the line is mostly going.
+	steep := math.Abs(y2-y1) > math.Abs(x2-x1)
+	for i := 0; i < int(math.Max(width, 1)); i++ {
+		dx, dy := 0, i
+		if steep {
+			dx, dy = i, 0
+		}
+		r.bresenham(int(math.Round(x1))+dx, int(math.Round(y1))+dy,
+			int(math.Round(x2))+dx, int(math.Round(y2))+dy, c)
+	}
+}
+
+// bresenham draws a 1 pixel line between two points.
+func (r *raster) bresenham(x0, y0, x1, y1 int, c color.RGBA) {
+
+	dx := abs(x1 - x0)
+	dy := -abs(y1 - y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+
+	err := dx + dy
+	for {
+		r.img.SetRGBA(x0, y0, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		if e2 := 2 * err; e2 >= dy {
+			err += dy
+			x0 += sx
+		} else {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(v int) int {
+
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,58 @@
+package chart
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+)
+
+// vector draws the chart as SVG elements.
+type vector struct {
+	out *bufio.Writer
+}
+
+// SVG writes the chart as an SVG document.
+func (c *Chart) SVG(out io.Writer) error {
+
+	w, h := c.size()
+	v := &vector{bufio.NewWriter(out)}
+	fmt.Fprintf(v.out, `<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="%g" viewBox="0 0 %g %g" font-family="monospace" font-size="12">`+"\n", w, h, w, h)
+	c.draw(v)
+	fmt.Fprintln(v.out, "</svg>")
+
+	return v.out.Flush()
+}
+
+// rgb returns the color in the form used by SVG.
+func rgb(c color.RGBA) string {
+	return fmt.Sprintf("rgb(%d,%d,%d)", c.R, c.G, c.B)
+}
+
+func (v *vector) rect(x, y, w, h float64, c color.RGBA) {
+	fmt.Fprintf(v.out, `<rect x="%g" y="%g" width="%g" height="%g" fill="%s"/>`+"\n", x, y, w, h, rgb(c))
+}
+
+func (v *vector) line(x1, y1, x2, y2 float64, c color.RGBA, width float64) {
+	fmt.Fprintf(v.out, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="%g"/>`+"\n",
+		x1, y1, x2, y2, rgb(c), width)
+}
+
+func (v *vector) polyline(xs, ys []float64, c color.RGBA) {
+
+	points := make([]string, len(xs))
+	for i := range xs {
+		points[i] = fmt.Sprintf("%.1f,%.1f", xs[i], ys[i])
+	}
+	fmt.Fprintf(v.out, `<polyline points="%s" fill="none" stroke="%s" stroke-width="1.5"/>`+"\n",
+		strings.Join(points, " "), rgb(c))
+}
+
+func (v *vector) text(x, y float64, s string, c color.RGBA) {
+
+	fmt.Fprintf(v.out, `<text x="%.1f" y="%.1f" fill="%s">`, x, y, rgb(c))
+	xml.EscapeText(v.out, []byte(s))
+	fmt.Fprintln(v.out, "</text>")
+}
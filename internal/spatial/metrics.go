@@ -0,0 +1,166 @@
+package spatial
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"lazyhacker.dev/wa-tor/internal/wator"
+)
+
+// Species holds the cluster statistics of one species.
+type Species struct {
+	Clusters     int         `json:"clusters"`      // Number of clusters.
+	MeanCluster  float64     `json:"mean_cluster"`  // Average cluster size.
+	LargestShare float64     `json:"largest_share"` // Fraction of the species in the largest cluster.
+	Sizes        []SizeCount `json:"sizes"`         // Cluster size distribution.
+	MoransI      float64     `json:"morans_i"`      // Spatial autocorrelation of the species.
+}
+
+// Metrics are the spatial patterns of the world at a chronon.  The pair
+// correlation functions are indexed by radius minus one.
+type Metrics struct {
+	Chronon    uint      `json:"chronon"`
+	Fish       Species   `json:"fish"`
+	Sharks     Species   `json:"sharks"`
+	FishFish   []float64 `json:"g_fish_fish"`
+	SharkShark []float64 `json:"g_shark_shark"`
+	SharkFish  []float64 `json:"g_shark_fish"`
+}
+
+// Measure computes the spatial metrics of a world state with pair
+// correlations up to maxRadius.
+func Measure(chronon uint, g Grid, maxRadius int) Metrics {
+
+	return Metrics{
+		Chronon:    chronon,
+		Fish:       g.species(wator.FISH),
+		Sharks:     g.species(wator.SHARK),
+		FishFish:   g.PairCorrelation(wator.FISH, wator.FISH, maxRadius),
+		SharkShark: g.PairCorrelation(wator.SHARK, wator.SHARK, maxRadius),
+		SharkFish:  g.PairCorrelation(wator.SHARK, wator.FISH, maxRadius),
+	}
+}
+
+// species computes the cluster statistics of a species.
+func (g Grid) species(species int) Species {
+
+	sizes := g.ClusterSizes(species)
+	s := Species{
+		Clusters: len(sizes),
+		Sizes:    Distribution(sizes),
+		MoransI:  g.MoransI(species),
+	}
+	if len(sizes) > 0 {
+		var total int
+		for _, n := range sizes {
+			total += n
+		}
+		s.MeanCluster = float64(total) / float64(len(sizes))
+		s.LargestShare = float64(sizes[0]) / float64(total)
+	}
+
+	return s
+}
+
+// Recorder measures the spatial metrics of a world every few chronons.
+// Measuring is much slower than a chronon on large worlds so it is best not
+// done every chronon.
+type Recorder struct {
+	wator.NopObserver
+	Every     uint      // Measure every Nth chronon.
+	MaxRadius int       // Largest radius of the pair correlations.
+	Samples   []Metrics // Measured metrics in chronon order.
+	cancel    func()
+}
+
+// NewRecorder attaches a recorder to the world and measures its current
+// state.
+func NewRecorder(w *wator.Wator, every uint, maxRadius int) *Recorder {
+
+	if every == 0 {
+		every = 1
+	}
+	r := &Recorder{Every: every, MaxRadius: maxRadius}
+	r.add(w.Chronon, Grid{w.State(), w.Width, w.Height})
+	r.cancel = w.Subscribe(r)
+
+	return r
+}
+
+// OnChrononEnd measures the world if the chronon falls on the interval.
+func (r *Recorder) OnChrononEnd(w *wator.Wator, ws wator.WorldStates) {
+	r.add(w.Chronon, Grid{ws.Current, w.Width, w.Height})
+}
+
+// Stop detaches the recorder from the world.
+func (r *Recorder) Stop() {
+
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+}
+
+func (r *Recorder) add(chronon uint, g Grid) {
+
+	if chronon%r.Every == 0 {
+		r.Samples = append(r.Samples, Measure(chronon, g, r.MaxRadius))
+	}
+}
+
+// WriteJSONL writes every measurement, including the cluster size
+// distributions, as a line of JSON.
+func (r *Recorder) WriteJSONL(out io.Writer) error {
+
+	enc := json.NewEncoder(out)
+	for _, m := range r.Samples {
+		if err := enc.Encode(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteCSV writes a row per measurement with the scalar metrics followed by
+// a column per radius of every pair correlation function.  The cluster size
+// distributions are left out.
+func (r *Recorder) WriteCSV(out io.Writer) error {
+
+	header := []string{
+		"chronon",
+		"fish_clusters", "fish_mean_cluster", "fish_largest_share", "fish_morans_i",
+		"shark_clusters", "shark_mean_cluster", "shark_largest_share", "shark_morans_i",
+	}
+	radius := 0
+	if len(r.Samples) > 0 {
+		radius = len(r.Samples[0].FishFish)
+	}
+	for _, name := range []string{"g_fish_fish", "g_shark_shark", "g_shark_fish"} {
+		for i := 1; i <= radius; i++ {
+			header = append(header, fmt.Sprintf("%s_%d", name, i))
+		}
+	}
+
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 4, 64) }
+
+	cw := csv.NewWriter(out)
+	cw.Write(header)
+	for _, m := range r.Samples {
+		row := []string{strconv.FormatUint(uint64(m.Chronon), 10)}
+		for _, s := range []Species{m.Fish, m.Sharks} {
+			row = append(row, strconv.Itoa(s.Clusters), f(s.MeanCluster), f(s.LargestShare), f(s.MoransI))
+		}
+		for _, g := range [][]float64{m.FishFish, m.SharkShark, m.SharkFish} {
+			for _, v := range g {
+				row = append(row, f(v))
+			}
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
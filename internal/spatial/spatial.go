@@ -0,0 +1,233 @@
+// Package spatial measures the patterns fish and sharks form on the toroidal
+// world of wa-tor: clusters of neighbouring creatures, pair correlation
+// functions and spatial autocorrelation (Moran's I).
+//
+// Positions are indexed like wator.WorldState, row by row, and the world
+// wraps around at every edge.  Neighbours are the 4 positions north, south,
+// east and west, the same positions a creature can move to.
+package spatial
+
+import (
+	"math"
+	"slices"
+
+	"lazyhacker.dev/wa-tor/internal/wator"
+)
+
+// Grid is a world state together with its dimensions.
+type Grid struct {
+	State         wator.WorldState
+	Width, Height int
+}
+
+// neighbours returns the 4 positions next to a position on the torus.
+func (g Grid) neighbours(pos int) [4]int {
+
+	x, y := pos%g.Width, pos/g.Width
+	return [4]int{
+		((y+g.Height-1)%g.Height)*g.Width + x,
+		((y+1)%g.Height)*g.Width + x,
+		y*g.Width + (x+g.Width-1)%g.Width,
+		y*g.Width + (x+1)%g.Width,
+	}
+}
+
+// Label assigns a cluster number, starting at 1, to every position holding
+// the species so that neighbouring positions of the species share a number.
+// Other positions are 0.  It returns the labels and the number of clusters.
+func (g Grid) Label(species int) ([]int, int) {
+
+	// Union-find over the positions of the species.
+	parent := make([]int, len(g.State))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+
+	for i, v := range g.State {
+		if v != species {
+			continue
+		}
+		for _, n := range g.neighbours(i) {
+			if g.State[n] == species {
+				if a, b := find(i), find(n); a != b {
+					parent[a] = b
+				}
+			}
+		}
+	}
+
+	labels := make([]int, len(g.State))
+	ids := make(map[int]int)
+	for i, v := range g.State {
+		if v != species {
+			continue
+		}
+		root := find(i)
+		id, ok := ids[root]
+		if !ok {
+			id = len(ids) + 1
+			ids[root] = id
+		}
+		labels[i] = id
+	}
+
+	return labels, len(ids)
+}
+
+// ClusterSizes returns the size of every cluster of the species, largest
+// first.
+func (g Grid) ClusterSizes(species int) []int {
+
+	labels, n := g.Label(species)
+	sizes := make([]int, n)
+	for _, l := range labels {
+		if l > 0 {
+			sizes[l-1]++
+		}
+	}
+	slices.SortFunc(sizes, func(a, b int) int { return b - a })
+
+	return sizes
+}
+
+// SizeCount is the number of clusters of a size.
+type SizeCount struct {
+	Size  int `json:"size"`
+	Count int `json:"count"`
+}
+
+// Distribution returns how many clusters there are of every size, smallest
+// size first.
+func Distribution(sizes []int) []SizeCount {
+
+	var dist []SizeCount
+	sorted := slices.Sorted(slices.Values(sizes))
+	for _, s := range sorted {
+		if n := len(dist); n > 0 && dist[n-1].Size == s {
+			dist[n-1].Count++
+		} else {
+			dist = append(dist, SizeCount{s, 1})
+		}
+	}
+	return dist
+}
+
+// PairCorrelation returns the radial distribution function g(r) of species b
+// around species a for r = 1 to maxRadius.  Entry r-1 is for the distances
+// in [r, r+1) and compares the number of b found at that distance from an a
+// to the number expected if b were spread uniformly: 1 means no correlation,
+// above 1 clustering and below 1 avoidance.  Distances are Euclidean on the
+// torus.  Radii with no positions at that distance or species that are absent
+// give 0.
+func (g Grid) PairCorrelation(a, b, maxRadius int) []float64 {
+
+	// Only look up to half the world so that no pair is counted twice by
+	// going around the torus.
+	maxRadius = min(maxRadius, (min(g.Width, g.Height)-1)/2)
+	if maxRadius < 1 {
+		return nil
+	}
+
+	counts := make([]float64, maxRadius)
+	shells := make([]float64, maxRadius)
+	type offset struct{ dx, dy, bin int }
+	var offsets []offset
+	for dy := -maxRadius; dy <= maxRadius; dy++ {
+		for dx := -maxRadius; dx <= maxRadius; dx++ {
+			bin := int(math.Sqrt(float64(dx*dx+dy*dy))) - 1
+			if bin < 0 || bin >= maxRadius {
+				continue
+			}
+			offsets = append(offsets, offset{dx, dy, bin})
+			shells[bin]++
+		}
+	}
+
+	var na, nb int
+	for i, v := range g.State {
+		if v == b {
+			nb++
+		}
+		if v != a {
+			continue
+		}
+		na++
+		x, y := i%g.Width, i/g.Width
+		for _, o := range offsets {
+			px := (x + o.dx + g.Width) % g.Width
+			py := (y + o.dy + g.Height) % g.Height
+			if g.State[py*g.Width+px] == b {
+				counts[o.bin]++
+			}
+		}
+	}
+
+	cells := len(g.State)
+	rdf := make([]float64, maxRadius)
+	if na == 0 || nb == 0 {
+		return rdf
+	}
+
+	// A creature can't be at a distance from itself.
+	density := float64(nb) / float64(cells)
+	if a == b {
+		if nb < 2 {
+			return rdf
+		}
+		density = float64(nb-1) / float64(cells-1)
+	}
+	for r := range rdf {
+		if shells[r] > 0 {
+			rdf[r] = counts[r] / (float64(na) * shells[r] * density)
+		}
+	}
+
+	return rdf
+}
+
+// MoransI returns the spatial autocorrelation of the presence of the species
+// with equal weights for the 4 neighbours.  It is close to 1 when the species
+// is clustered, close to 0 when it is spread randomly and negative when it
+// avoids itself like a checkerboard.  A world where every position does or
+// doesn't hold the species has no variation and returns 0.
+func (g Grid) MoransI(species int) float64 {
+
+	n := len(g.State)
+	var present int
+	for _, v := range g.State {
+		if v == species {
+			present++
+		}
+	}
+	if present == 0 || present == n {
+		return 0
+	}
+
+	m := float64(present) / float64(n)
+	value := func(v int) float64 {
+		if v == species {
+			return 1 - m
+		}
+		return -m
+	}
+
+	var num, den float64
+	for i, v := range g.State {
+		xi := value(v)
+		den += xi * xi
+		for _, j := range g.neighbours(i) {
+			num += xi * value(g.State[j])
+		}
+	}
+
+	// Every position has 4 neighbours so the sum of the weights is 4n.
+	return float64(n) / float64(4*n) * num / den
+}
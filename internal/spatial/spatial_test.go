@@ -0,0 +1,178 @@
+package spatial
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"lazyhacker.dev/wa-tor/internal/wator"
+)
+
+const (
+	F = wator.FISH
+	S = wator.SHARK
+	o = wator.NONE
+)
+
+func TestLabel(t *testing.T) {
+	// The fish on the left and right edges touch across the wrap.
+	g := Grid{
+		State: wator.WorldState{
+			F, o, o, F,
+			o, o, S, o,
+			o, S, S, o,
+			F, o, o, o,
+		},
+		Width: 4, Height: 4,
+	}
+
+	labels, n := g.Label(F)
+	if n != 1 {
+		t.Errorf("Expected 1 fish cluster wrapping around the edges, got %d: %v", n, labels)
+	}
+	if labels[0] != labels[3] || labels[0] != labels[12] || labels[1] != 0 {
+		t.Errorf("Unexpected labels %v", labels)
+	}
+
+	if got := g.ClusterSizes(S); !reflect.DeepEqual(got, []int{3}) {
+		t.Errorf("Expected one shark cluster of 3, got %v", got)
+	}
+}
+
+func TestClusterSizes(t *testing.T) {
+	g := Grid{
+		State: wator.WorldState{
+			F, F, o, o, o,
+			o, o, o, F, o,
+			o, F, o, F, o,
+			o, o, o, F, o,
+			o, o, o, o, o,
+		},
+		Width: 5, Height: 5,
+	}
+	sizes := g.ClusterSizes(F)
+	if !reflect.DeepEqual(sizes, []int{3, 2, 1}) {
+		t.Errorf("Expected clusters of 3, 2 and 1, got %v", sizes)
+	}
+	if got := Distribution([]int{3, 1, 1, 2, 1}); !reflect.DeepEqual(got, []SizeCount{{1, 3}, {2, 1}, {3, 1}}) {
+		t.Errorf("Unexpected distribution %v", got)
+	}
+}
+
+func TestMoransI(t *testing.T) {
+	checkerboard := Grid{Width: 4, Height: 4}
+	halves := Grid{Width: 4, Height: 4}
+	for i := 0; i < 16; i++ {
+		x, y := i%4, i/4
+		if (x+y)%2 == 0 {
+			checkerboard.State = append(checkerboard.State, F)
+		} else {
+			checkerboard.State = append(checkerboard.State, o)
+		}
+		if y < 2 {
+			halves.State = append(halves.State, F)
+		} else {
+			halves.State = append(halves.State, o)
+		}
+	}
+
+	if got := checkerboard.MoransI(F); math.Abs(got+1) > 1e-9 {
+		t.Errorf("Expected -1 for a checkerboard, got %v", got)
+	}
+	// Each row touches 3 rows of the same kind out of 4 neighbours.
+	if got := halves.MoransI(F); math.Abs(got-0.5) > 1e-9 {
+		t.Errorf("Expected 0.5 for two halves, got %v", got)
+	}
+	if got := halves.MoransI(S); got != 0 {
+		t.Errorf("Expected 0 for an absent species, got %v", got)
+	}
+}
+
+func TestPairCorrelation(t *testing.T) {
+	// Fish everywhere are uniformly spread.
+	full := Grid{State: make(wator.WorldState, 100), Width: 10, Height: 10}
+	for i := range full.State {
+		full.State[i] = F
+	}
+	rdf := full.PairCorrelation(F, F, 3)
+	if len(rdf) != 3 {
+		t.Fatalf("Expected 3 radii, got %d", len(rdf))
+	}
+	for r, v := range rdf {
+		if math.Abs(v-1) > 1e-9 {
+			t.Errorf("Radius %d: expected 1 for a uniform world, got %v", r+1, v)
+		}
+	}
+
+	// A shark surrounded by fish.
+	g := Grid{State: make(wator.WorldState, 49), Width: 7, Height: 7}
+	g.State[24] = S
+	for _, n := range g.neighbours(24) {
+		g.State[n] = F
+	}
+	rdf = g.PairCorrelation(S, F, 3)
+	if rdf[0] <= 1 || rdf[1] != 0 || rdf[2] != 0 {
+		t.Errorf("Expected fish only next to the shark, got %v", rdf)
+	}
+
+	if rdf := g.PairCorrelation(S, S, 3); rdf[0] != 0 {
+		t.Errorf("Expected 0 for a single shark, got %v", rdf)
+	}
+	if rdf := (Grid{State: make(wator.WorldState, 4), Width: 2, Height: 2}).PairCorrelation(F, F, 3); rdf != nil {
+		t.Errorf("Expected no radius fits in a 2x2 world, got %v", rdf)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	world := wator.Wator{Seed: 9}
+	if err := world.Init(20, 20, 150, 30, 3, 6, 4); err != nil {
+		t.Fatal(err)
+	}
+	rec := NewRecorder(&world, 5, 4)
+	for i := 0; i < 12; i++ {
+		world.Update()
+	}
+	rec.Stop()
+
+	if len(rec.Samples) != 3 {
+		t.Fatalf("Expected chronons 0, 5 and 10, got %d samples", len(rec.Samples))
+	}
+	first := rec.Samples[0]
+	if first.Fish.Clusters == 0 || len(first.FishFish) != 4 {
+		t.Errorf("Unexpected metrics %+v", first)
+	}
+	var total int
+	for _, sc := range first.Fish.Sizes {
+		total += sc.Size * sc.Count
+	}
+	if total != 150 {
+		t.Errorf("Expected the clusters to hold the 150 fish, got %d", total)
+	}
+
+	var csv bytes.Buffer
+	if err := rec.WriteCSV(&csv); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(csv.String()), "\n")
+	if len(lines) != 4 || !strings.HasSuffix(lines[0], "g_shark_fish_4") {
+		t.Errorf("Unexpected CSV:\n%s", csv.String())
+	}
+	if cols := len(strings.Split(lines[1], ",")); cols != 9+3*4 {
+		t.Errorf("Expected 21 columns, got %d", cols)
+	}
+
+	var jsonl bytes.Buffer
+	if err := rec.WriteJSONL(&jsonl); err != nil {
+		t.Fatal(err)
+	}
+	var m Metrics
+	if err := json.Unmarshal([]byte(strings.Split(jsonl.String(), "\n")[2]), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Chronon != 10 || !reflect.DeepEqual(m.Fish.Sizes, rec.Samples[2].Fish.Sizes) {
+		t.Errorf("Unexpected JSON metrics %+v", m)
+	}
+}
@@ -5,9 +5,12 @@ import (
 )
 
 type creature struct {
-	chronon   int  // age of the creature.
-	turn      uint // the chronon when it last moved.
-	direction int  // direction creature is facing
+	chronon    int    // age of the creature.
+	turn       uint   // the chronon when it last moved.
+	direction  int    // direction creature is facing
+	id         uint64 // number of the creature, unique in its world.
+	parent     uint64 // id of the creature that spawned it, 0 for none.
+	generation int    // number of ancestors.
 }
 
 func (c *creature) setAge(a int) {
@@ -37,25 +40,26 @@ type shark struct {
 	creature
 }
 
-// NewShark returns a new instance of a Shark.
-func NewShark() *shark {
+// NewShark returns a new instance of a Shark that starves after health
+// chronons without eating.
+func NewShark(health int) *shark {
 	return &shark{
-		sharkHealth,
+		health,
 		creature{},
 	}
 }
 
 // spawn returns whether it should spawn a new shark.
-func (s *shark) spawn() bool {
+func (s *shark) spawn(rate int) bool {
 
-	if s.chronon%sharkSpawnRate == 0 && s.chronon > 0 {
+	if s.chronon%rate == 0 && s.chronon > 0 {
 		return true
 	}
 	return false
 }
 
 // move determines how a shark moves.
-func (s *shark) move(pos int, world []worldItem, adjacents []int) int {
+func (s *shark) move(pos int, world []worldItem, adjacents []int, r *rand.Rand) int {
 
 	var openTiles []int
 	// Shark cannot move to tiles that have other sharks
@@ -71,7 +75,7 @@ func (s *shark) move(pos int, world []worldItem, adjacents []int) int {
 		}
 	}
 
-	return pickPosition(pos, openTiles)
+	return pickPosition(r, pos, openTiles)
 
 }
 
@@ -83,9 +87,9 @@ func (s *shark) starve() int {
 	return s.health
 }
 
-// feed adjusts the shark's health when it eats a fish.
-func (s *shark) feed() {
-	s.health = sharkHealth
+// feed restores the shark's health when it eats a fish.
+func (s *shark) feed(health int) {
+	s.health = health
 }
 
 // fish is a creature of Wa-tor who eats the planktons in the water.  They
@@ -102,16 +106,16 @@ func NewFish() *fish {
 }
 
 // spawn determines whether a new fish should spawn.
-func (f *fish) spawn() bool {
+func (f *fish) spawn(rate int) bool {
 
-	if f.chronon%fishSpawnRate == 0 && f.chronon > 0 {
+	if f.chronon%rate == 0 && f.chronon > 0 {
 		return true
 	}
 	return false
 }
 
 // move handles the fish's movement.
-func (f *fish) move(pos int, world []worldItem, adjacents []int) int {
+func (f *fish) move(pos int, world []worldItem, adjacents []int, r *rand.Rand) int {
 
 	var openTiles []int
 	// Fish can only move to non-occupied squares.
@@ -121,14 +125,14 @@ func (f *fish) move(pos int, world []worldItem, adjacents []int) int {
 		}
 	}
 
-	return pickPosition(pos, openTiles)
+	return pickPosition(r, pos, openTiles)
 }
 
 // pickPosition randomly picks the element from the given slice.
-func pickPosition(curr int, numbers []int) int {
+func pickPosition(r *rand.Rand, curr int, numbers []int) int {
 
 	if len(numbers) == 0 {
 		return curr
 	}
-	return numbers[rand.Intn(len(numbers))]
+	return numbers[r.Intn(len(numbers))]
 }
@@ -55,3 +55,57 @@ func (d *Delta) Dump() {
 	fmt.Printf("Animal = %v from %d to %d Action=%v\n", obj, d.From, d.To, action)
 
 }
+
+// Apply makes the changes of a ChangeLog to the state in order so that
+// applying the ChangeLog of a chronon to its Previous state gives its Current
+// state.
+func (ws WorldState) Apply(changes []Delta) {
+
+	for _, d := range changes {
+		switch d.Action {
+		case BIRTH:
+			ws[d.From] = d.Object
+		case DEATH:
+			ws[d.From] = NONE
+		case ATE:
+			ws[d.To] = NONE
+		default:
+			ws[d.To], ws[d.From] = ws[d.From], ws[d.To]
+		}
+	}
+}
+
+// Invert returns the changes that undo a ChangeLog so that applying the
+// inverse of the ChangeLog of a chronon to its Current state gives its
+// Previous state.  The changes are in the order they undo the ChangeLog:
+// moves go back the opposite way, births become deaths and the other way
+// around and a fish that was eaten is born again where it was.
+func Invert(changes []Delta) []Delta {
+
+	inverse := make([]Delta, 0, len(changes))
+	for i := len(changes) - 1; i >= 0; i-- {
+		d := changes[i]
+		switch d.Action {
+		case BIRTH:
+			d.Action = DEATH
+		case DEATH:
+			d.Action = BIRTH
+		case ATE:
+			d = Delta{Object: FISH, From: d.To, To: d.To, Action: BIRTH}
+		case MOVE_NORTH, MOVE_SOUTH, MOVE_EAST, MOVE_WEST:
+			d.From, d.To = d.To, d.From
+			d.Action = opposite[d.Action]
+		}
+		inverse = append(inverse, d)
+	}
+
+	return inverse
+}
+
+// opposite is the direction that undoes a move.
+var opposite = map[int]int{
+	MOVE_NORTH: MOVE_SOUTH,
+	MOVE_SOUTH: MOVE_NORTH,
+	MOVE_EAST:  MOVE_WEST,
+	MOVE_WEST:  MOVE_EAST,
+}
@@ -17,10 +17,10 @@ func Example() {
 }
 
 func ExampleNewShark() {
-	s := NewShark()
-	fmt.Println(s.age())
+	s := NewShark(5)
+	fmt.Println(s.age(), s.health)
 	// Output:
-	// 0
+	// 0 5
 }
 
 func ExampleWator_Update() {
@@ -2,6 +2,7 @@ package wator_test
 
 import (
 	"bytes"
+	"reflect"
 	"testing"
 
 	"lazyhacker.dev/wa-tor/internal/wator"
@@ -102,3 +103,148 @@ func TestDebugPrint(t *testing.T) {
 	_ = buf // Currently unused; in a more advanced test you might capture and analyze the output.
 	world.DebugPrint()
 }
+
+func TestSeedReproducible(t *testing.T) {
+	var a, b wator.Wator
+	a.Seed, b.Seed = 42, 42
+	if err := a.Init(8, 8, 20, 5, 3, 4, 3); err != nil {
+		t.Fatalf("Unexpected error during Init: %v", err)
+	}
+	if err := b.Init(8, 8, 20, 5, 3, 4, 3); err != nil {
+		t.Fatalf("Unexpected error during Init: %v", err)
+	}
+	for i := 0; i < 30; i++ {
+		sa, sb := a.Update(), b.Update()
+		for j := range sa.Current {
+			if sa.Current[j] != sb.Current[j] {
+				t.Fatalf("Chronon %d: worlds with the same seed diverged at %d", a.Chronon, j)
+			}
+		}
+	}
+	if p := a.Params(); p.Seed != 42 || p.Fish != 20 || p.Sharks != 5 || p.Health != 3 {
+		t.Errorf("Unexpected params %+v", p)
+	}
+}
+
+func TestSeedPicked(t *testing.T) {
+	var world wator.Wator
+	if err := world.Init(3, 3, 2, 1, 3, 3, 2); err != nil {
+		t.Fatalf("Unexpected error during Init: %v", err)
+	}
+	if world.Seed == 0 {
+		t.Error("Expected Init to pick a seed")
+	}
+}
+
+func TestReseed(t *testing.T) {
+	var a, b wator.Wator
+	a.Seed = 3
+	if err := a.Init(10, 10, 30, 5, 3, 5, 3); err != nil {
+		t.Fatalf("Unexpected error during Init: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		a.Update()
+	}
+	if err := b.Restore(a.Snapshot()); err != nil {
+		t.Fatal(err)
+	}
+
+	b.Reseed(99)
+	if b.Seed != 99 {
+		t.Errorf("Expected seed 99, got %d", b.Seed)
+	}
+	differ := false
+	for i := 0; i < 5 && !differ; i++ {
+		differ = !reflect.DeepEqual(a.Update().Current, b.Update().Current)
+	}
+	if !differ {
+		t.Error("Expected a reseeded world to evolve differently")
+	}
+}
+
+func TestSetRules(t *testing.T) {
+	world := wator.Wator{Seed: 4}
+	if err := world.Init(10, 10, 30, 10, 3, 8, 6); err != nil {
+		t.Fatalf("Unexpected error during Init: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		fsr, ssr, hlth int
+		wantErr        bool
+	}{
+		{"valid", 5, 10, 2, false},
+		{"health above shark spawn rate", 5, 3, 4, true},
+		{"zero fish spawn rate", 0, 10, 2, true},
+		{"negative health", 5, 10, -1, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			before := world.Params()
+			err := world.SetRules(tc.fsr, tc.ssr, tc.hlth)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Expected error %v, got %v", tc.wantErr, err)
+			}
+			p := world.Params()
+			if tc.wantErr && p != before {
+				t.Errorf("Expected the rules to stay %+v, got %+v", before, p)
+			}
+			if !tc.wantErr && (p.FishSpawnRate != tc.fsr || p.SharkSpawnRate != tc.ssr || p.Health != tc.hlth) {
+				t.Errorf("Expected the new rules, got %+v", p)
+			}
+		})
+	}
+
+	world.Update()
+	if s := world.Stats(); s.Sharks > 0 && s.MeanSharkHealth > 2 {
+		t.Errorf("Expected the sharks' health to be at most 2, got a mean of %v", s.MeanSharkHealth)
+	}
+}
+
+func TestPlace(t *testing.T) {
+	world := wator.Wator{Seed: 1}
+	if err := world.Init(3, 3, 0, 0, 3, 5, 2); err != nil {
+		t.Fatalf("Unexpected error during Init: %v", err)
+	}
+
+	tests := []struct {
+		pos, species int
+		wantErr      bool
+	}{
+		{0, wator.FISH, false},
+		{4, wator.SHARK, false},
+		{8, wator.FISH, false},
+		{8, wator.NONE, false},
+		{9, wator.FISH, true},
+		{-1, wator.SHARK, true},
+		{1, 7, true},
+	}
+	for _, tc := range tests {
+		if err := world.Place(tc.pos, tc.species); (err != nil) != tc.wantErr {
+			t.Errorf("Place(%d, %d): expected error %v, got %v", tc.pos, tc.species, tc.wantErr, err)
+		}
+	}
+
+	want := wator.WorldState{wator.FISH, 0, 0, 0, wator.SHARK, 0, 0, 0, 0}
+	if got := wator.WorldState(world.State()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if s := world.Stats(); s.Fish != 1 || s.Sharks != 1 {
+		t.Errorf("Expected 1 fish and 1 shark, got %d and %d", s.Fish, s.Sharks)
+	}
+
+	// Placing after the statistics were counted counts them again.
+	world.Place(1, wator.FISH)
+	world.Place(0, wator.NONE)
+	world.Place(2, wator.SHARK)
+	if s := world.Snapshot().Stats; s.Fish != 1 || s.Sharks != 2 || s.Occupancy != 3.0/9 {
+		t.Errorf("Expected 1 fish and 2 sharks in the snapshot, got %+v", s)
+	}
+
+	// The placed creatures take part in the next chronon.
+	world.Update()
+	if s := world.Stats(); s.Fish+s.Sharks == 0 {
+		t.Errorf("Expected the placed creatures to live on, got %+v", s)
+	}
+}
@@ -0,0 +1,202 @@
+package wator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// statsFields maps the names usable in stop expressions to the statistic
+// they stand for.
+var statsFields = map[string]func(Stats) float64{
+	"chronon":           func(s Stats) float64 { return float64(s.Chronon) },
+	"fish":              func(s Stats) float64 { return float64(s.Fish) },
+	"sharks":            func(s Stats) float64 { return float64(s.Sharks) },
+	"fish_born":         func(s Stats) float64 { return float64(s.FishBorn) },
+	"sharks_born":       func(s Stats) float64 { return float64(s.SharksBorn) },
+	"fish_eaten":        func(s Stats) float64 { return float64(s.FishEaten) },
+	"sharks_starved":    func(s Stats) float64 { return float64(s.SharksStarved) },
+	"births":            func(s Stats) float64 { return float64(s.Births()) },
+	"deaths":            func(s Stats) float64 { return float64(s.Deaths()) },
+	"mean_fish_age":     func(s Stats) float64 { return s.MeanFishAge },
+	"max_fish_age":      func(s Stats) float64 { return float64(s.MaxFishAge) },
+	"mean_shark_age":    func(s Stats) float64 { return s.MeanSharkAge },
+	"max_shark_age":     func(s Stats) float64 { return float64(s.MaxSharkAge) },
+	"mean_shark_health": func(s Stats) float64 { return s.MeanSharkHealth },
+	"occupancy":         func(s Stats) float64 { return s.Occupancy },
+}
+
+// predicate is a compiled stop expression.
+type predicate func(Stats) bool
+
+// ParseStopExpr creates a condition from an expression comparing statistics
+// to numbers, such as "sharks < 5 or chronon > 10000".  Comparisons use <,
+// <=, >, >=, == or != with the statistic on the left and can be combined with
+// "and", "or" (or && and ||) and parentheses.  The names are the JSON names
+// of the Stats fields plus births and deaths.
+func ParseStopExpr(expr string) (StopCondition, error) {
+
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{tokens: tokens}
+	match, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("Unexpected %q in stop expression.", p.tokens[p.pos])
+	}
+
+	return func(w *Wator, s Stats) string {
+		if match(s) {
+			return "stop condition met: " + expr
+		}
+		return ""
+	}, nil
+}
+
+// tokenize splits an expression into names, numbers, operators and
+// parentheses.
+func tokenize(expr string) ([]string, error) {
+
+	var tokens []string
+	for i := 0; i < len(expr); {
+		c := rune(expr[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		case strings.ContainsRune("<>=!&|", c):
+			j := i + 1
+			if j < len(expr) && strings.ContainsRune("=&|", rune(expr[j])) {
+				j++
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '.' || c == '-':
+			j := i
+			for j < len(expr) && (unicode.IsLetter(rune(expr[j])) || unicode.IsDigit(rune(expr[j])) ||
+				strings.ContainsRune("_.-", rune(expr[j]))) {
+				j++
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		default:
+			return nil, fmt.Errorf("Unexpected character %q in stop expression.", c)
+		}
+	}
+
+	return tokens, nil
+}
+
+// exprParser is a recursive descent parser of stop expressions.
+type exprParser struct {
+	tokens []string
+	pos    int
+}
+
+// peek returns the next token or an empty string at the end.
+func (p *exprParser) peek() string {
+
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+// next consumes the next token.
+func (p *exprParser) next() string {
+
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+// or parses comparisons joined by "or".
+func (p *exprParser) or() (predicate, error) {
+
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t == "or" || t == "||"; t = p.peek() {
+		p.next()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(s Stats) bool { return l(s) || right(s) }
+	}
+	return left, nil
+}
+
+// and parses comparisons joined by "and".
+func (p *exprParser) and() (predicate, error) {
+
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t == "and" || t == "&&"; t = p.peek() {
+		p.next()
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(s Stats) bool { return l(s) && right(s) }
+	}
+	return left, nil
+}
+
+// term parses a comparison or an expression in parentheses.
+func (p *exprParser) term() (predicate, error) {
+
+	if p.peek() == "(" {
+		p.next()
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("Missing ')' in stop expression.")
+		}
+		return inner, nil
+	}
+
+	name := p.next()
+	field, ok := statsFields[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown statistic %q in stop expression.", name)
+	}
+
+	op := p.next()
+	value, err := strconv.ParseFloat(p.next(), 64)
+	if err != nil {
+		return nil, fmt.Errorf("Expected a number after %s %s in stop expression.", name, op)
+	}
+
+	switch op {
+	case "<":
+		return func(s Stats) bool { return field(s) < value }, nil
+	case "<=":
+		return func(s Stats) bool { return field(s) <= value }, nil
+	case ">":
+		return func(s Stats) bool { return field(s) > value }, nil
+	case ">=":
+		return func(s Stats) bool { return field(s) >= value }, nil
+	case "==", "=":
+		return func(s Stats) bool { return field(s) == value }, nil
+	case "!=":
+		return func(s Stats) bool { return field(s) != value }, nil
+	}
+
+	return nil, fmt.Errorf("Unknown comparison %q in stop expression.", op)
+}
@@ -0,0 +1,67 @@
+package wator
+
+// Observer receives the events of a Wator as they happen during Update.
+// Hooks are invoked synchronously and in the same order as the ChangeLog.
+// Every event is delivered after the change it describes has been applied
+// to the world so an observer looking at the Wator always sees a consistent
+// world.  Observers must not call Update or otherwise modify the Wator.
+type Observer interface {
+	OnChrononStart(w *Wator)               // Called before any creature takes its turn.
+	OnBirth(w *Wator, d Delta)             // A creature spawned a new one at d.To.
+	OnMove(w *Wator, d Delta)              // A creature moved (or stayed with MOVE_NONE).
+	OnEat(w *Wator, d Delta)               // The shark at d.From ate the fish at d.To.
+	OnDeath(w *Wator, d Delta)             // A creature died at d.From.
+	OnChrononEnd(w *Wator, ws WorldStates) // Called after every creature had its turn.
+}
+
+// NopObserver implements Observer with hooks that do nothing.  Embed it to
+// only implement the hooks of interest.
+type NopObserver struct{}
+
+func (NopObserver) OnChrononStart(*Wator)            {}
+func (NopObserver) OnBirth(*Wator, Delta)            {}
+func (NopObserver) OnMove(*Wator, Delta)             {}
+func (NopObserver) OnEat(*Wator, Delta)              {}
+func (NopObserver) OnDeath(*Wator, Delta)            {}
+func (NopObserver) OnChrononEnd(*Wator, WorldStates) {}
+
+// subscription wraps an observer so that it can be removed even when the
+// observer itself is not comparable.
+type subscription struct {
+	observer Observer
+}
+
+// Subscribe attaches an observer to the world.  The returned function detaches
+// it again and is safe to call from within a hook.
+func (w *Wator) Subscribe(o Observer) (cancel func()) {
+
+	s := &subscription{o}
+	w.observers = append(w.observers, s)
+
+	return func() {
+		for i, sub := range w.observers {
+			if sub == s {
+				// Copy so that a dispatch in progress keeps its own list.
+				w.observers = append(w.observers[:i:i], w.observers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// notify delivers a changelog entry to the hook matching its action.
+func (w *Wator) notify(d Delta) {
+
+	for _, s := range w.observers {
+		switch d.Action {
+		case BIRTH:
+			s.observer.OnBirth(w, d)
+		case ATE:
+			s.observer.OnEat(w, d)
+		case DEATH:
+			s.observer.OnDeath(w, d)
+		default:
+			s.observer.OnMove(w, d)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package wator_test
+
+import (
+	"testing"
+
+	"lazyhacker.dev/wa-tor/internal/wator"
+)
+
+// recorder keeps every event it observes and checks that the world agrees
+// with the event at the time it is delivered.
+type recorder struct {
+	t      *testing.T
+	starts int
+	ends   int
+	events []wator.Delta
+}
+
+func (r *recorder) OnChrononStart(w *wator.Wator) { r.starts++ }
+
+func (r *recorder) OnBirth(w *wator.Wator, d wator.Delta) {
+	r.events = append(r.events, d)
+	if got := w.State()[d.To]; got != d.Object {
+		r.t.Errorf("OnBirth: position %d holds %d, expected %d", d.To, got, d.Object)
+	}
+}
+
+func (r *recorder) OnMove(w *wator.Wator, d wator.Delta) {
+	r.events = append(r.events, d)
+	if got := w.State()[d.To]; got != d.Object {
+		r.t.Errorf("OnMove: position %d holds %d, expected %d", d.To, got, d.Object)
+	}
+}
+
+func (r *recorder) OnEat(w *wator.Wator, d wator.Delta) {
+	r.events = append(r.events, d)
+	if got := w.State()[d.To]; got != wator.NONE {
+		r.t.Errorf("OnEat: eaten fish still at %d (holds %d)", d.To, got)
+	}
+}
+
+func (r *recorder) OnDeath(w *wator.Wator, d wator.Delta) {
+	r.events = append(r.events, d)
+	if got := w.State()[d.From]; got != wator.NONE {
+		r.t.Errorf("OnDeath: position %d holds %d, expected NONE", d.From, got)
+	}
+}
+
+func (r *recorder) OnChrononEnd(w *wator.Wator, ws wator.WorldStates) {
+	r.ends++
+	if len(ws.ChangeLog) != len(r.events) {
+		r.t.Errorf("OnChrononEnd: observed %d events, changelog has %d", len(r.events), len(ws.ChangeLog))
+		return
+	}
+	for i, d := range ws.ChangeLog {
+		if d != r.events[i] {
+			r.t.Errorf("OnChrononEnd: event %d is %+v, changelog has %+v", i, r.events[i], d)
+		}
+	}
+}
+
+func TestObserverEvents(t *testing.T) {
+	var world wator.Wator
+	if err := world.Init(8, 8, 20, 8, 3, 4, 3); err != nil {
+		t.Fatalf("Unexpected error during Init: %v", err)
+	}
+
+	r := &recorder{t: t}
+	world.Subscribe(r)
+	for i := 0; i < 20; i++ {
+		r.events = nil
+		world.Update()
+	}
+	if r.starts != 20 || r.ends != 20 {
+		t.Errorf("Expected 20 chronon start/end events, got %d/%d", r.starts, r.ends)
+	}
+}
+
+func TestObserverCancel(t *testing.T) {
+	var world wator.Wator
+	if err := world.Init(4, 4, 4, 2, 3, 3, 2); err != nil {
+		t.Fatalf("Unexpected error during Init: %v", err)
+	}
+
+	r := &recorder{t: t}
+	cancel := world.Subscribe(r)
+	world.Update()
+	cancel()
+	world.Update()
+	if r.starts != 1 {
+		t.Errorf("Expected observer to see 1 chronon after cancel, got %d", r.starts)
+	}
+}
+
+// births only counts births and relies on NopObserver for the other hooks.
+type births struct {
+	wator.NopObserver
+	count int
+}
+
+func (b *births) OnBirth(*wator.Wator, wator.Delta) { b.count++ }
+
+func TestObserverNop(t *testing.T) {
+	var world wator.Wator
+	if err := world.Init(4, 4, 8, 0, 1, 3, 2); err != nil {
+		t.Fatalf("Unexpected error during Init: %v", err)
+	}
+	b := &births{}
+	world.Subscribe(b)
+	world.Update()
+	world.Update()
+	if b.count == 0 {
+		t.Error("Expected fish with spawn rate 1 to give birth")
+	}
+}
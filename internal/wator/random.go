@@ -0,0 +1,61 @@
+package wator
+
+import "math/rand"
+
+// source is a xoshiro256** random number generator.  Unlike the sources of
+// math/rand its state can be read and restored, which allows a saved world to
+// continue exactly as the original would have.
+type source struct {
+	s [4]uint64
+}
+
+// newSource returns a source seeded with the seed.
+func newSource(seed int64) *source {
+
+	src := &source{}
+	src.Seed(seed)
+	return src
+}
+
+// Seed initializes the state from the seed with splitmix64 so that similar
+// seeds give unrelated sequences.
+func (src *source) Seed(seed int64) {
+
+	x := uint64(seed)
+	for i := range src.s {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		src.s[i] = z ^ (z >> 31)
+	}
+}
+
+// Uint64 returns the next random number.
+func (src *source) Uint64() uint64 {
+
+	s := &src.s
+	result := rotl(s[1]*5, 7) * 9
+	t := s[1] << 17
+
+	s[2] ^= s[0]
+	s[3] ^= s[1]
+	s[1] ^= s[2]
+	s[0] ^= s[3]
+	s[2] ^= t
+	s[3] = rotl(s[3], 45)
+
+	return result
+}
+
+// Int63 returns a non-negative random number.
+func (src *source) Int63() int64 {
+	return int64(src.Uint64() >> 1)
+}
+
+func rotl(x uint64, k uint) uint64 {
+	return (x << k) | (x >> (64 - k))
+}
+
+// Ensure that the source can back a rand.Rand.
+var _ rand.Source64 = (*source)(nil)
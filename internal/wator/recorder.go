@@ -0,0 +1,308 @@
+package wator
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Recorder accumulates the statistics of a world in memory as it evolves so
+// that the population can be exported as a time series.
+//
+// # Usage:
+//
+//	rec := wator.NewRecorder(&world, 10)
+//	for i := 0; i < 1000; i++ {
+//		world.Update()
+//	}
+//	rec.WriteCSV(os.Stdout)
+type Recorder struct {
+	NopObserver
+	Params  Params  // Settings of the recorded world.
+	Every   uint    // Keep the statistics of every Nth chronon.
+	Samples []Stats // Recorded statistics in chronon order.
+	cancel  func()
+}
+
+// statsColumns are the names of the columns of the exported time series.
+var statsColumns = []string{
+	"chronon", "fish", "sharks", "fish_born", "sharks_born", "fish_eaten",
+	"sharks_starved", "mean_fish_age", "max_fish_age", "mean_shark_age",
+	"max_shark_age", "mean_shark_health", "occupancy",
+}
+
+// NewRecorder attaches a recorder to the world and records its current
+// statistics.  Only chronons that are a multiple of every are kept; 0 or 1
+// keeps every chronon.
+func NewRecorder(w *Wator, every uint) *Recorder {
+
+	if every == 0 {
+		every = 1
+	}
+	r := &Recorder{
+		Params: w.Params(),
+		Every:  every,
+	}
+	r.add(w.Stats())
+	r.cancel = w.Subscribe(r)
+
+	return r
+}
+
+// OnChrononEnd records the statistics of the chronon that just finished.
+func (r *Recorder) OnChrononEnd(w *Wator, ws WorldStates) {
+	r.add(ws.Stats)
+}
+
+// Stop detaches the recorder from the world.  The samples recorded so far are
+// kept.
+func (r *Recorder) Stop() {
+
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+}
+
+// add keeps the statistics if it falls on the sampling interval.
+func (r *Recorder) add(s Stats) {
+
+	if s.Chronon%r.Every == 0 {
+		r.Samples = append(r.Samples, s)
+	}
+}
+
+// WriteCSV writes the samples as CSV.  The world parameters are written first
+// as a comment line starting with '#' followed by a header row.
+func (r *Recorder) WriteCSV(out io.Writer) error {
+
+	if _, err := fmt.Fprintf(out, "# %v every=%d\n", r.Params, r.Every); err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(out)
+	if err := cw.Write(statsColumns); err != nil {
+		return err
+	}
+	for _, s := range r.Samples {
+		if err := cw.Write(s.record()); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// WriteJSONL writes the samples as JSON Lines.  The first line holds the
+// world parameters and each following line the statistics of one chronon.
+func (r *Recorder) WriteJSONL(out io.Writer) error {
+
+	enc := json.NewEncoder(out)
+	header := struct {
+		Params Params `json:"params"`
+		Every  uint   `json:"every"`
+	}{r.Params, r.Every}
+
+	if err := enc.Encode(header); err != nil {
+		return err
+	}
+	for _, s := range r.Samples {
+		if err := enc.Encode(s); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// record returns the statistics as CSV fields in the order of statsColumns.
+func (s Stats) record() []string {
+
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+
+	return []string{
+		strconv.FormatUint(uint64(s.Chronon), 10),
+		strconv.Itoa(s.Fish),
+		strconv.Itoa(s.Sharks),
+		strconv.Itoa(s.FishBorn),
+		strconv.Itoa(s.SharksBorn),
+		strconv.Itoa(s.FishEaten),
+		strconv.Itoa(s.SharksStarved),
+		f(s.MeanFishAge),
+		strconv.Itoa(s.MaxFishAge),
+		f(s.MeanSharkAge),
+		strconv.Itoa(s.MaxSharkAge),
+		f(s.MeanSharkHealth),
+		f(s.Occupancy),
+	}
+}
+
+// ReadCSV reads statistics written by WriteCSV.  Every header comment starts a
+// new run, as written by several recorders to the same file, so one recorder
+// is returned per run.  The rows of each run are read with encoding/csv like
+// WriteCSV writes them.  The recorders are not attached to any world.
+func ReadCSV(in io.Reader) ([]*Recorder, error) {
+
+	var runs []*Recorder
+	var rows strings.Builder
+	first := 1 // Line of the first of the rows.
+
+	flush := func() error {
+		if rows.Len() == 0 {
+			return nil
+		}
+		// Statistics without a header belong to a run with unknown
+		// parameters.
+		if len(runs) == 0 {
+			runs = append(runs, &Recorder{Every: 1})
+		}
+		err := readRows(rows.String(), first, runs[len(runs)-1])
+		rows.Reset()
+		return err
+	}
+
+	scanner := bufio.NewScanner(in)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if !strings.HasPrefix(strings.TrimSpace(text), "#") {
+			rows.WriteString(text)
+			rows.WriteByte('\n')
+			continue
+		}
+
+		if err := flush(); err != nil {
+			return nil, err
+		}
+		r, err := parseHeader(strings.TrimPrefix(strings.TrimSpace(text), "#"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		runs = append(runs, r)
+		first = line + 1
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+// readRows adds the statistics of the CSV rows to the recorder, skipping the
+// column names.  first is the line of the rows in the file, for the errors.
+func readRows(rows string, first int, r *Recorder) error {
+
+	cr := csv.NewReader(strings.NewReader(rows))
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var pe *csv.ParseError
+		if errors.As(err, &pe) {
+			return fmt.Errorf("line %d: %v", first+pe.Line-1, pe.Err)
+		}
+		if err != nil {
+			return err
+		}
+		if record[0] == statsColumns[0] {
+			continue
+		}
+
+		s, err := parseStats(record)
+		if err != nil {
+			line, _ := cr.FieldPos(0)
+			return fmt.Errorf("line %d: %v", first+line-1, err)
+		}
+		r.Samples = append(r.Samples, s)
+	}
+}
+
+// parseHeader reads the key=value pairs of the comment written by WriteCSV.
+func parseHeader(header string) (*Recorder, error) {
+
+	r := &Recorder{Every: 1}
+	p := &r.Params
+	fields := map[string]*int{
+		"width":            &p.Width,
+		"height":           &p.Height,
+		"fish":             &p.Fish,
+		"sharks":           &p.Sharks,
+		"fish-spawn-rate":  &p.FishSpawnRate,
+		"shark-spawn-rate": &p.SharkSpawnRate,
+		"health":           &p.Health,
+	}
+
+	for _, pair := range strings.Fields(header) {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("Invalid header field %q.", pair)
+		}
+
+		var err error
+		switch key {
+		case "seed":
+			p.Seed, err = strconv.ParseInt(value, 10, 64)
+		case "every":
+			var every uint64
+			every, err = strconv.ParseUint(value, 10, 0)
+			r.Every = uint(every)
+		default:
+			// Ignore fields this version doesn't know about.
+			if f, ok := fields[key]; ok {
+				*f, err = strconv.Atoi(value)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid header field %q.", pair)
+		}
+	}
+
+	return r, nil
+}
+
+// parseStats reads the CSV fields written by record.
+func parseStats(fields []string) (Stats, error) {
+
+	var s Stats
+	if len(fields) != len(statsColumns) {
+		return s, fmt.Errorf("Expected %d columns, got %d.", len(statsColumns), len(fields))
+	}
+
+	// Destination of each column in the order of statsColumns.
+	targets := []any{
+		&s.Chronon, &s.Fish, &s.Sharks, &s.FishBorn, &s.SharksBorn,
+		&s.FishEaten, &s.SharksStarved, &s.MeanFishAge, &s.MaxFishAge,
+		&s.MeanSharkAge, &s.MaxSharkAge, &s.MeanSharkHealth, &s.Occupancy,
+	}
+
+	for i, field := range fields {
+		var err error
+		switch t := targets[i].(type) {
+		case *uint:
+			var v uint64
+			v, err = strconv.ParseUint(field, 10, 0)
+			*t = uint(v)
+		case *int:
+			*t, err = strconv.Atoi(field)
+		case *float64:
+			*t, err = strconv.ParseFloat(field, 64)
+		}
+		if err != nil {
+			return s, fmt.Errorf("Invalid %s %q.", statsColumns[i], field)
+		}
+	}
+
+	return s, nil
+}
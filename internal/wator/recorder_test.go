@@ -0,0 +1,172 @@
+package wator_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"lazyhacker.dev/wa-tor/internal/wator"
+)
+
+func TestRecorderDownsample(t *testing.T) {
+	world := wator.Wator{Seed: 7}
+	if err := world.Init(6, 6, 10, 3, 3, 4, 3); err != nil {
+		t.Fatalf("Unexpected error during Init: %v", err)
+	}
+	rec := wator.NewRecorder(&world, 5)
+	for i := 0; i < 20; i++ {
+		world.Update()
+	}
+	rec.Stop()
+	world.Update()
+
+	// Chronon 0, 5, 10, 15 and 20.
+	if len(rec.Samples) != 5 {
+		t.Fatalf("Expected 5 samples, got %d", len(rec.Samples))
+	}
+	for i, s := range rec.Samples {
+		if s.Chronon != uint(i*5) {
+			t.Errorf("Sample %d: expected chronon %d, got %d", i, i*5, s.Chronon)
+		}
+	}
+}
+
+func TestRecorderWriteCSV(t *testing.T) {
+	world := wator.Wator{Seed: 7}
+	if err := world.Init(6, 6, 10, 3, 3, 4, 3); err != nil {
+		t.Fatalf("Unexpected error during Init: %v", err)
+	}
+	rec := wator.NewRecorder(&world, 1)
+	world.Update()
+	world.Update()
+
+	var buf bytes.Buffer
+	if err := rec.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("Expected comment, header and 3 rows, got %d lines:\n%s", len(lines), buf.String())
+	}
+	if want := "# width=6 height=6 fish=10 sharks=3 fish-spawn-rate=3 shark-spawn-rate=4 health=3 seed=7 every=1"; lines[0] != want {
+		t.Errorf("Expected header %q, got %q", want, lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "chronon,fish,sharks,") {
+		t.Errorf("Unexpected column header %q", lines[1])
+	}
+	if !strings.HasPrefix(lines[2], "0,10,3,") {
+		t.Errorf("Unexpected first row %q", lines[2])
+	}
+}
+
+func TestRecorderWriteJSONL(t *testing.T) {
+	world := wator.Wator{Seed: 7}
+	if err := world.Init(6, 6, 10, 3, 3, 4, 3); err != nil {
+		t.Fatalf("Unexpected error during Init: %v", err)
+	}
+	rec := wator.NewRecorder(&world, 1)
+	world.Update()
+
+	var buf bytes.Buffer
+	if err := rec.WriteJSONL(&buf); err != nil {
+		t.Fatalf("WriteJSONL: %v", err)
+	}
+
+	scanner := bufio.NewScanner(&buf)
+	scanner.Scan()
+	var header struct {
+		Params wator.Params
+		Every  uint
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		t.Fatalf("Unable to decode header: %v", err)
+	}
+	if header.Params != world.Params() || header.Every != 1 {
+		t.Errorf("Unexpected header %+v", header)
+	}
+
+	var got []wator.Stats
+	for scanner.Scan() {
+		var s wator.Stats
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			t.Fatalf("Unable to decode stats: %v", err)
+		}
+		got = append(got, s)
+	}
+	if len(got) != 2 || got[1] != world.Stats() {
+		t.Errorf("Expected 2 samples ending with %+v, got %+v", world.Stats(), got)
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	var buf bytes.Buffer
+	var recorders []*wator.Recorder
+	for _, seed := range []int64{7, 8} {
+		world := wator.Wator{Seed: seed}
+		if err := world.Init(6, 6, 10, 3, 3, 4, 3); err != nil {
+			t.Fatalf("Unexpected error during Init: %v", err)
+		}
+		rec := wator.NewRecorder(&world, 2)
+		for i := 0; i < 10; i++ {
+			world.Update()
+		}
+		rec.Stop()
+		if err := rec.WriteCSV(&buf); err != nil {
+			t.Fatal(err)
+		}
+		recorders = append(recorders, rec)
+	}
+
+	runs, err := wator.ReadCSV(&buf)
+	if err != nil {
+		t.Fatalf("ReadCSV: %v", err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("Expected 2 runs, got %d", len(runs))
+	}
+	for i, r := range runs {
+		want := recorders[i]
+		if r.Params != want.Params || r.Every != want.Every {
+			t.Errorf("Run %d: expected %v every=%d, got %v every=%d", i, want.Params, want.Every, r.Params, r.Every)
+		}
+		if len(r.Samples) != len(want.Samples) {
+			t.Fatalf("Run %d: expected %d samples, got %d", i, len(want.Samples), len(r.Samples))
+		}
+		for j := range r.Samples {
+			if r.Samples[j] != want.Samples[j] {
+				t.Errorf("Run %d sample %d: expected %+v, got %+v", i, j, want.Samples[j], r.Samples[j])
+			}
+		}
+	}
+}
+
+func TestReadCSVQuoted(t *testing.T) {
+	in := "# width=4 height=4 every=1\n\"chronon\",fish\n\"3\",\"5\",2,0,0,0,0,1.5,3,2,4,1,0.4375\n"
+	runs, err := wator.ReadCSV(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("ReadCSV: %v", err)
+	}
+	if len(runs) != 1 || len(runs[0].Samples) != 1 {
+		t.Fatalf("Expected 1 run with 1 sample, got %d runs", len(runs))
+	}
+	if s := runs[0].Samples[0]; s.Chronon != 3 || s.Fish != 5 || s.Occupancy != 0.4375 {
+		t.Errorf("Expected the quoted fields to be read, got %+v", s)
+	}
+}
+
+func TestReadCSVErrors(t *testing.T) {
+	tests := []string{
+		"# width=x\n",
+		"# width\n",
+		"chronon,fish\n1,2\n",
+		"1,2,3,4,5,6,7,a,9,10,11,12,13\n",
+		"\"1,2,3,4,5,6,7,8,9,10,11,12,13\n",
+	}
+	for _, in := range tests {
+		if _, err := wator.ReadCSV(strings.NewReader(in)); err == nil {
+			t.Errorf("ReadCSV(%q): expected an error", in)
+		}
+	}
+}
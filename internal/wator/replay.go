@@ -0,0 +1,282 @@
+package wator
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ReplayVersion is the version of the replay format written by this package.
+const ReplayVersion = 1
+
+// Replay is a recorded run: the complete world at the start and the
+// ChangeLog of every chronon that followed.
+//
+// A replay is written as JSON Lines.  The first line holds the version and
+// the starting snapshot and each following line the ChangeLog of one chronon
+// as a flat array of Object, From, To and Action for every change.
+type Replay struct {
+	Start   Snapshot  // World before the first recorded chronon.
+	Changes [][]Delta // ChangeLog of every recorded chronon in order.
+}
+
+// replayHeader is the first line of a replay.
+type replayHeader struct {
+	Version int      `json:"version"`
+	Start   Snapshot `json:"start"`
+}
+
+// ReplayWriter writes the replay of a world as it evolves.
+//
+// # Usage:
+//
+//	rw, err := wator.NewReplayWriter(&world, out)
+//	for i := 0; i < 1000; i++ {
+//		world.Update()
+//	}
+//	err = rw.Stop()
+type ReplayWriter struct {
+	NopObserver
+	out     *bufio.Writer
+	closers []io.Closer // Closed in order by Stop.
+	buf     []int
+	err     error
+	cancel  func()
+}
+
+// NewReplayWriter attaches a writer to the world and writes the current state
+// of the world as the start of the replay.
+func NewReplayWriter(w *Wator, out io.Writer) (*ReplayWriter, error) {
+
+	r := &ReplayWriter{out: bufio.NewWriter(out)}
+	header := replayHeader{ReplayVersion, w.Snapshot()}
+	if err := json.NewEncoder(r.out).Encode(header); err != nil {
+		return nil, err
+	}
+	r.cancel = w.Subscribe(r)
+
+	return r, nil
+}
+
+// CreateReplay creates the file and starts writing the replay of the world to
+// it.  Files with names ending in .gz are compressed.
+func CreateReplay(w *Wator, name string) (*ReplayWriter, error) {
+
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	var out io.Writer = f
+	closers := []io.Closer{f}
+	if strings.HasSuffix(name, ".gz") {
+		gz := gzip.NewWriter(f)
+		out = gz
+		closers = []io.Closer{gz, f}
+	}
+
+	r, err := NewReplayWriter(w, out)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.closers = closers
+
+	return r, nil
+}
+
+// OnChrononEnd writes the ChangeLog of the chronon that just finished.
+func (r *ReplayWriter) OnChrononEnd(w *Wator, ws WorldStates) {
+
+	if r.err != nil {
+		return
+	}
+	r.buf = r.buf[:0]
+	for _, d := range ws.ChangeLog {
+		r.buf = append(r.buf, d.Object, d.From, d.To, d.Action)
+	}
+
+	line, err := json.Marshal(r.buf)
+	if err == nil {
+		_, err = r.out.Write(append(line, '\n'))
+	}
+	r.err = err
+}
+
+// Stop detaches the writer from the world, flushes what was written and
+// returns the first error that happened while writing.
+func (r *ReplayWriter) Stop() error {
+
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+	if err := r.out.Flush(); r.err == nil {
+		r.err = err
+	}
+	for _, c := range r.closers {
+		if err := c.Close(); r.err == nil {
+			r.err = err
+		}
+	}
+	r.closers = nil
+
+	return r.err
+}
+
+// ReadReplay reads a replay written by a ReplayWriter, compressed or not.
+func ReadReplay(in io.Reader) (*Replay, error) {
+
+	br := bufio.NewReader(in)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+
+	dec := json.NewDecoder(br)
+	var header replayHeader
+	if err := dec.Decode(&header); err != nil {
+		return nil, fmt.Errorf("Invalid replay. %v", err)
+	}
+	if header.Version < 1 || header.Version > ReplayVersion {
+		return nil, fmt.Errorf("Unsupported replay version %d.", header.Version)
+	}
+	// Restoring checks the snapshot is a valid world.
+	var w Wator
+	if err := w.Restore(header.Start); err != nil {
+		return nil, err
+	}
+
+	r := &Replay{Start: header.Start}
+	size := len(w.world)
+	for chronon := header.Start.Chronon + 1; dec.More(); chronon++ {
+		var values []int
+		if err := dec.Decode(&values); err != nil {
+			return nil, fmt.Errorf("Invalid changes of chronon %d. %v", chronon, err)
+		}
+		if len(values)%4 != 0 {
+			return nil, fmt.Errorf("Incomplete change in chronon %d.", chronon)
+		}
+
+		changes := make([]Delta, 0, len(values)/4)
+		for i := 0; i < len(values); i += 4 {
+			d := Delta{Object: values[i], From: values[i+1], To: values[i+2], Action: values[i+3]}
+			if d.From < 0 || d.From >= size || d.To < 0 || d.To >= size {
+				return nil, fmt.Errorf("Change in chronon %d is outside the world.", chronon)
+			}
+			changes = append(changes, d)
+		}
+		r.Changes = append(r.Changes, changes)
+	}
+
+	return r, nil
+}
+
+// keyframeInterval is the number of chronons between the states a Player
+// keeps to seek without replaying from the start.
+const keyframeInterval = 64
+
+// Player steps through a replay forward and seeks to any recorded chronon.
+type Player struct {
+	replay    *Replay
+	step      int        // Number of ChangeLogs applied to the start.
+	state     WorldState // State after step ChangeLogs.
+	keyframes [][]byte   // State after every keyframeInterval ChangeLogs.
+}
+
+// NewPlayer returns a player positioned at the start of the replay.
+func NewPlayer(r *Replay) *Player {
+
+	p := &Player{replay: r, state: r.Start.State()}
+	p.keyframes = append(p.keyframes, pack(p.state))
+
+	return p
+}
+
+// Params returns the settings of the recorded world.
+func (p *Player) Params() Params {
+	return p.replay.Start.Params
+}
+
+// Chronon returns the chronon of the current state.
+func (p *Player) Chronon() uint {
+	return p.First() + uint(p.step)
+}
+
+// First returns the first chronon of the replay.
+func (p *Player) First() uint {
+	return p.replay.Start.Chronon
+}
+
+// Last returns the last chronon of the replay.
+func (p *Player) Last() uint {
+	return p.First() + uint(len(p.replay.Changes))
+}
+
+// State returns the positions of the fish and sharks at the current chronon.
+func (p *Player) State() WorldState {
+	return append(WorldState{}, p.state...)
+}
+
+// Next advances to the next chronon and returns its ChangeLog.  It returns
+// false at the end of the replay.
+func (p *Player) Next() ([]Delta, bool) {
+
+	if p.step >= len(p.replay.Changes) {
+		return nil, false
+	}
+	changes := p.replay.Changes[p.step]
+	p.state.Apply(changes)
+	p.step++
+	if p.step%keyframeInterval == 0 && p.step/keyframeInterval == len(p.keyframes) {
+		p.keyframes = append(p.keyframes, pack(p.state))
+	}
+
+	return changes, true
+}
+
+// Seek moves to the chronon, which is limited to the recorded ones.
+func (p *Player) Seek(chronon uint) {
+
+	chronon = min(max(chronon, p.First()), p.Last())
+	step := int(chronon - p.First())
+
+	// Start from the latest known keyframe unless the current state is
+	// closer.
+	k := min(step/keyframeInterval, len(p.keyframes)-1)
+	if step < p.step || k*keyframeInterval > p.step {
+		p.step = k * keyframeInterval
+		p.state = unpack(p.keyframes[k])
+	}
+	for p.step < step {
+		p.Next()
+	}
+}
+
+// pack stores a state in a byte per position.
+func pack(ws WorldState) []byte {
+
+	b := make([]byte, len(ws))
+	for i, v := range ws {
+		b[i] = byte(v)
+	}
+	return b
+}
+
+// unpack returns the state stored by pack.
+func unpack(b []byte) WorldState {
+
+	ws := make(WorldState, len(b))
+	for i, v := range b {
+		ws[i] = int(v)
+	}
+	return ws
+}
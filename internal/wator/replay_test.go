@@ -0,0 +1,163 @@
+package wator_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"lazyhacker.dev/wa-tor/internal/wator"
+)
+
+func TestApply(t *testing.T) {
+	world := wator.Wator{Seed: 5}
+	if err := world.Init(8, 6, 15, 5, 3, 5, 3); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 40; i++ {
+		ws := world.Update()
+		state := append(wator.WorldState{}, ws.Previous...)
+		state.Apply(ws.ChangeLog)
+		if !reflect.DeepEqual(state, ws.Current) {
+			t.Fatalf("Chronon %d: applying the changelog to Previous doesn't give Current", world.Chronon)
+		}
+	}
+}
+
+// record runs a world for the number of chronons while writing its replay
+// and returns the replay and the state of every chronon.
+func record(t *testing.T, steps int) ([]byte, []wator.WorldState) {
+	t.Helper()
+
+	world := wator.Wator{Seed: 9}
+	if err := world.Init(10, 8, 25, 6, 3, 5, 3); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		world.Update()
+	}
+
+	var buf bytes.Buffer
+	rw, err := wator.NewReplayWriter(&world, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := []wator.WorldState{world.State()}
+	for i := 0; i < steps; i++ {
+		states = append(states, world.Update().Current)
+	}
+	if err := rw.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	world.Update()
+
+	return buf.Bytes(), states
+}
+
+func TestReplayPlayback(t *testing.T) {
+	data, states := record(t, 150)
+
+	r, err := wator.ReadReplay(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Changes) != 150 {
+		t.Fatalf("Expected 150 chronons, got %d", len(r.Changes))
+	}
+
+	p := wator.NewPlayer(r)
+	if p.First() != 5 || p.Last() != 155 {
+		t.Errorf("Expected chronons 5 to 155, got %d to %d", p.First(), p.Last())
+	}
+	for i, want := range states {
+		if !reflect.DeepEqual(p.State(), want) {
+			t.Fatalf("Chronon %d: state differs from the recorded world", p.Chronon())
+		}
+		if _, ok := p.Next(); ok != (i < len(states)-1) {
+			t.Fatalf("Chronon %d: expected Next to report %v", p.Chronon(), !ok)
+		}
+	}
+
+	for _, chronon := range []uint{100, 20, 154, 70, 0, 500} {
+		p.Seek(chronon)
+		want := min(max(chronon, 5), 155)
+		if p.Chronon() != want {
+			t.Errorf("Seek(%d): expected chronon %d, got %d", chronon, want, p.Chronon())
+		}
+		if !reflect.DeepEqual(p.State(), states[want-5]) {
+			t.Errorf("Seek(%d): state differs from the recorded world", chronon)
+		}
+	}
+}
+
+func TestCreateReplayCompressed(t *testing.T) {
+	world := wator.Wator{Seed: 2}
+	if err := world.Init(6, 6, 8, 2, 3, 5, 3); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "run.jsonl.gz")
+	rw, err := wator.CreateReplay(&world, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		world.Update()
+	}
+	if err := rw.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := wator.ReadReplay(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Changes) != 10 {
+		t.Errorf("Expected 10 chronons, got %d", len(r.Changes))
+	}
+}
+
+func TestReadReplayErrors(t *testing.T) {
+	data, _ := record(t, 2)
+	header, _, _ := strings.Cut(string(data), "\n")
+
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"empty", ""},
+		{"version", `{"version":9,"start":{"version":1}}`},
+		{"incomplete change", header + "\n[1,2,3]\n"},
+		{"outside world", header + "\n[1,2,500,3]\n"},
+		{"not numbers", header + "\n{}\n"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := wator.ReadReplay(strings.NewReader(tc.in)); err == nil {
+				t.Errorf("Expected an error reading %q", tc.in)
+			}
+		})
+	}
+}
+
+func TestInvert(t *testing.T) {
+	world := wator.Wator{Seed: 6}
+	if err := world.Init(8, 6, 15, 5, 3, 5, 3); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 40; i++ {
+		ws := world.Update()
+		state := append(wator.WorldState{}, ws.Current...)
+		state.Apply(wator.Invert(ws.ChangeLog))
+		if !reflect.DeepEqual(state, ws.Previous) {
+			t.Fatalf("Chronon %d: applying the inverse to Current doesn't give Previous", world.Chronon)
+		}
+	}
+}
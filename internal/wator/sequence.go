@@ -9,14 +9,14 @@ type sequence struct {
 }
 
 // init creates a slice of sequential integers and then shuffle them.
-func (s *sequence) init(size int) {
+func (s *sequence) init(size int, r *rand.Rand) {
 	s.seq = make([]int, size)
 	for i := 0; i < size; i++ {
 		s.seq[i] = int(i)
 	}
 
 	// Shuffle the sequence
-	rand.Shuffle(len(s.seq), func(i, j int) {
+	r.Shuffle(len(s.seq), func(i, j int) {
 		s.seq[i], s.seq[j] = s.seq[j], s.seq[i]
 	})
 }
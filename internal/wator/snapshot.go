@@ -0,0 +1,436 @@
+package wator
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+)
+
+// SnapshotVersion is the version of the snapshot format written by this
+// package.  Version 2 added the ids and the lineage of the creatures, which
+// are given new ids when a version 1 snapshot is restored.
+const SnapshotVersion = 2
+
+// snapshotMagic starts the binary encoding of a snapshot.
+var snapshotMagic = []byte("WATR")
+
+// Snapshot is the complete state of a world: its parameters, the state of its
+// randomness and every creature.  Restoring a snapshot gives a world that
+// continues exactly as the original would have.
+type Snapshot struct {
+	Version   int        `json:"version"`
+	Params    Params     `json:"params"`
+	Chronon   uint       `json:"chronon"`
+	RNG       [4]uint64  `json:"rng"`
+	Stats     Stats      `json:"stats"`             // Statistics of the latest chronon.
+	LastID    uint64     `json:"last_id,omitempty"` // id of the latest creature.
+	Creatures []Creature `json:"creatures"`
+}
+
+// Creature is a fish or shark in a snapshot.
+type Creature struct {
+	Position   int    `json:"position"`
+	Species    int    `json:"species"` // FISH or SHARK
+	Age        int    `json:"age"`
+	Health     int    `json:"health,omitempty"`     // Only for sharks.
+	LastMove   uint   `json:"last_move"`            // Chronon the creature last had a turn.
+	Direction  int    `json:"direction"`            // Direction the creature is facing.
+	ID         uint64 `json:"id,omitempty"`         // Unique in its world, 0 if unknown.
+	Parent     uint64 `json:"parent,omitempty"`     // id of the creature that spawned it.
+	Generation int    `json:"generation,omitempty"` // Number of ancestors.
+}
+
+// Snapshot returns the complete state of the world.
+func (w *Wator) Snapshot() Snapshot {
+
+	w.random()
+	s := Snapshot{
+		Version: SnapshotVersion,
+		Params:  w.Params(),
+		Chronon: w.Chronon,
+		RNG:     w.src.s,
+		Stats:   w.Stats(),
+		LastID:  w.lastID,
+	}
+
+	for i := range w.world {
+		if c, ok := w.Creature(i); ok {
+			s.Creatures = append(s.Creatures, c)
+		}
+	}
+
+	return s
+}
+
+// Creature returns the creature at the position.  It returns false if there
+// is none.
+func (w *Wator) Creature(pos int) (Creature, bool) {
+
+	if pos < 0 || pos >= len(w.world) {
+		return Creature{}, false
+	}
+	c := Creature{Position: pos}
+	var cr *creature
+	switch t := w.world[pos].(type) {
+	case *fish:
+		c.Species, cr = FISH, &t.creature
+	case *shark:
+		c.Species, cr = SHARK, &t.creature
+		c.Health = t.health
+	default:
+		return Creature{}, false
+	}
+	c.Age, c.LastMove, c.Direction = cr.chronon, cr.turn, cr.direction
+	c.ID, c.Parent, c.Generation = cr.id, cr.parent, cr.generation
+	return c, true
+}
+
+// Find returns the position of the creature with the id.  It returns false
+// if the creature is not in the world, such as after it died.
+func (w *Wator) Find(id uint64) (int, bool) {
+
+	for i, tile := range w.world {
+		switch t := tile.(type) {
+		case *fish:
+			if t.id == id {
+				return i, true
+			}
+		case *shark:
+			if t.id == id {
+				return i, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// NextSpawn returns the number of chronons until the creature spawns with
+// the spawn rates of the parameters, 1 for the next one.  A creature that
+// can't move when it is due spawns on its next move instead.
+func (p Params) NextSpawn(c Creature) int {
+
+	rate := p.FishSpawnRate
+	if c.Species == SHARK {
+		rate = p.SharkSpawnRate
+	}
+	if rate <= 0 {
+		return 0
+	}
+	// A creature spawns during the chronon it starts at an age that is a
+	// multiple of the rate.
+	r := c.Age % rate
+	if r == 0 && c.Age > 0 {
+		return 1
+	}
+	return rate - r + 1
+}
+
+// Creature returns the creature of the snapshot at the position.  It returns
+// false if there is none.
+func (s Snapshot) Creature(pos int) (Creature, bool) {
+
+	for _, c := range s.Creatures {
+		if c.Position == pos {
+			return c, true
+		}
+	}
+	return Creature{}, false
+}
+
+// State returns the species at every position of the snapshot like
+// Wator.State.
+func (s Snapshot) State() WorldState {
+
+	ws := make(WorldState, s.Params.Width*s.Params.Height)
+	for _, c := range s.Creatures {
+		if c.Position >= 0 && c.Position < len(ws) {
+			ws[c.Position] = c.Species
+		}
+	}
+	return ws
+}
+
+// Restore replaces the state of the world with the snapshot.  Observers stay
+// subscribed.
+func (w *Wator) Restore(s Snapshot) error {
+
+	if s.Version < 1 || s.Version > SnapshotVersion {
+		return fmt.Errorf("Unsupported snapshot version %d.", s.Version)
+	}
+	p := s.Params
+	if p.Width <= 0 || p.Height <= 0 {
+		return fmt.Errorf("Invalid world size %dx%d in snapshot.", p.Width, p.Height)
+	}
+	if tooLarge(p.Width, p.Height) {
+		return fmt.Errorf("World of %dx%d in snapshot has more than %d positions.", p.Width, p.Height, MaxCells)
+	}
+	if p.FishSpawnRate <= 0 || p.SharkSpawnRate <= 0 {
+		return fmt.Errorf("Invalid spawn rates in snapshot.")
+	}
+	// xoshiro only ever returns 0 from an all zero state.
+	if s.RNG == [4]uint64{} {
+		return fmt.Errorf("Missing random number state in snapshot.")
+	}
+
+	world := make([]worldItem, p.Width*p.Height)
+	lastID := s.LastID
+	for _, c := range s.Creatures {
+		lastID = max(lastID, c.ID)
+	}
+	for _, c := range s.Creatures {
+		if c.Position < 0 || c.Position >= len(world) {
+			return fmt.Errorf("Creature at %d is outside the world.", c.Position)
+		}
+		if world[c.Position] != nil {
+			return fmt.Errorf("More than one creature at %d.", c.Position)
+		}
+
+		cr := creature{chronon: c.Age, turn: c.LastMove, direction: c.Direction,
+			id: c.ID, parent: c.Parent, generation: c.Generation}
+		if cr.id == 0 {
+			// Creatures of version 1 snapshots had no ids.
+			lastID++
+			cr.id = lastID
+		}
+		switch c.Species {
+		case FISH:
+			world[c.Position] = &fish{cr}
+		case SHARK:
+			if c.Health <= 0 {
+				return fmt.Errorf("Shark at %d has no health left.", c.Position)
+			}
+			world[c.Position] = &shark{c.Health, cr}
+		default:
+			return fmt.Errorf("Unknown species %d at %d.", c.Species, c.Position)
+		}
+	}
+
+	w.world = world
+	w.Width, w.Height = p.Width, p.Height
+	w.numFish, w.numSharks = p.Fish, p.Sharks
+	w.fishSpawnRate, w.sharkSpawnRate = p.FishSpawnRate, p.SharkSpawnRate
+	w.sharkHealth = p.Health
+	w.Seed = p.Seed
+	w.Chronon = s.Chronon
+	w.src = &source{s.RNG}
+	w.rng = rand.New(w.src)
+	w.stats = s.Stats
+	w.stale = false
+	w.lastID = lastID
+
+	return nil
+}
+
+// WriteJSON writes the snapshot as indented JSON.
+func (s Snapshot) WriteJSON(out io.Writer) error {
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// MarshalBinary encodes the snapshot in a compact binary form.  After the
+// magic bytes and the version, every number is a varint and the position of
+// each creature is stored as the distance from the previous one.
+func (s Snapshot) MarshalBinary() ([]byte, error) {
+
+	b := append([]byte{}, snapshotMagic...)
+	b = binary.AppendUvarint(b, uint64(s.Version))
+
+	p := s.Params
+	for _, v := range []int{p.Width, p.Height, p.Fish, p.Sharks, p.FishSpawnRate, p.SharkSpawnRate, p.Health} {
+		b = binary.AppendVarint(b, int64(v))
+	}
+	b = binary.AppendVarint(b, p.Seed)
+	b = binary.AppendUvarint(b, uint64(s.Chronon))
+	for _, v := range s.RNG {
+		b = binary.LittleEndian.AppendUint64(b, v)
+	}
+	if s.Version >= 2 {
+		b = binary.AppendUvarint(b, s.LastID)
+	}
+
+	// Stats are stored as JSON to keep the binary format independent of the
+	// fields of Stats.
+	stats, err := json.Marshal(s.Stats)
+	if err != nil {
+		return nil, err
+	}
+	b = binary.AppendUvarint(b, uint64(len(stats)))
+	b = append(b, stats...)
+
+	b = binary.AppendUvarint(b, uint64(len(s.Creatures)))
+	prev := 0
+	for _, c := range s.Creatures {
+		b = binary.AppendVarint(b, int64(c.Position-prev))
+		prev = c.Position
+		b = binary.AppendUvarint(b, uint64(c.Species))
+		b = binary.AppendVarint(b, int64(c.Age))
+		b = binary.AppendVarint(b, int64(c.Health))
+		b = binary.AppendUvarint(b, uint64(c.LastMove))
+		b = binary.AppendVarint(b, int64(c.Direction))
+		if s.Version >= 2 {
+			b = binary.AppendUvarint(b, c.ID)
+			b = binary.AppendUvarint(b, c.Parent)
+			b = binary.AppendVarint(b, int64(c.Generation))
+		}
+	}
+
+	return b, nil
+}
+
+// UnmarshalBinary decodes a snapshot encoded by MarshalBinary.
+func (s *Snapshot) UnmarshalBinary(data []byte) error {
+
+	if !bytes.HasPrefix(data, snapshotMagic) {
+		return fmt.Errorf("Not a binary snapshot.")
+	}
+	r := &binaryReader{data: data[len(snapshotMagic):]}
+
+	var n Snapshot
+	n.Version = int(r.uvarint())
+	if n.Version < 1 || n.Version > SnapshotVersion {
+		return fmt.Errorf("Unsupported snapshot version %d.", n.Version)
+	}
+
+	p := &n.Params
+	for _, v := range []*int{&p.Width, &p.Height, &p.Fish, &p.Sharks, &p.FishSpawnRate, &p.SharkSpawnRate, &p.Health} {
+		*v = int(r.varint())
+	}
+	p.Seed = r.varint()
+	n.Chronon = uint(r.uvarint())
+	for i := range n.RNG {
+		n.RNG[i] = r.uint64()
+	}
+	if n.Version >= 2 {
+		n.LastID = r.uvarint()
+	}
+
+	stats := r.bytes(int(r.uvarint()))
+	if r.err == nil {
+		if err := json.Unmarshal(stats, &n.Stats); err != nil {
+			return fmt.Errorf("Invalid statistics in snapshot. %v", err)
+		}
+	}
+
+	count := r.uvarint()
+	if count > uint64(len(r.data)) {
+		return fmt.Errorf("Invalid number of creatures in snapshot.")
+	}
+	pos := 0
+	for i := uint64(0); i < count && r.err == nil; i++ {
+		pos += int(r.varint())
+		c := Creature{
+			Position:  pos,
+			Species:   int(r.uvarint()),
+			Age:       int(r.varint()),
+			Health:    int(r.varint()),
+			LastMove:  uint(r.uvarint()),
+			Direction: int(r.varint()),
+		}
+		if n.Version >= 2 {
+			c.ID = r.uvarint()
+			c.Parent = r.uvarint()
+			c.Generation = int(r.varint())
+		}
+		n.Creatures = append(n.Creatures, c)
+	}
+	if r.err != nil {
+		return fmt.Errorf("Truncated snapshot. %v", r.err)
+	}
+
+	*s = n
+	return nil
+}
+
+// binaryReader decodes the numbers of a binary snapshot and remembers the
+// first error so that it only has to be checked at the end.
+type binaryReader struct {
+	data []byte
+	err  error
+}
+
+func (r *binaryReader) uvarint() uint64 {
+
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *binaryReader) varint() int64 {
+
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *binaryReader) uint64() uint64 {
+
+	b := r.bytes(8)
+	if r.err != nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+func (r *binaryReader) bytes(n int) []byte {
+
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data) {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+// ReadSnapshot reads a snapshot in either the JSON or the binary format.
+func ReadSnapshot(in io.Reader) (Snapshot, error) {
+
+	var s Snapshot
+	br := bufio.NewReader(in)
+	magic, err := br.Peek(len(snapshotMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return s, err
+	}
+
+	if bytes.Equal(magic, snapshotMagic) {
+		data, err := io.ReadAll(br)
+		if err != nil {
+			return s, err
+		}
+		err = s.UnmarshalBinary(data)
+		return s, err
+	}
+
+	if err := json.NewDecoder(br).Decode(&s); err != nil {
+		return s, fmt.Errorf("Invalid snapshot. %v", err)
+	}
+	if s.Version < 1 || s.Version > SnapshotVersion {
+		return s, fmt.Errorf("Unsupported snapshot version %d.", s.Version)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,298 @@
+package wator_test
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"lazyhacker.dev/wa-tor/internal/wator"
+)
+
+// roundTrip encodes and decodes the snapshot in the format.
+func roundTrip(t *testing.T, s wator.Snapshot, format string) wator.Snapshot {
+	t.Helper()
+
+	var buf bytes.Buffer
+	switch format {
+	case "json":
+		if err := s.WriteJSON(&buf); err != nil {
+			t.Fatal(err)
+		}
+	case "binary":
+		data, err := s.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf.Write(data)
+	}
+
+	got, err := wator.ReadSnapshot(&buf)
+	if err != nil {
+		t.Fatalf("ReadSnapshot(%s): %v", format, err)
+	}
+	return got
+}
+
+func TestSnapshotResume(t *testing.T) {
+	for _, format := range []string{"json", "binary"} {
+		t.Run(format, func(t *testing.T) {
+			original := wator.Wator{Seed: 21}
+			if err := original.Init(12, 10, 40, 10, 3, 6, 4); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 30; i++ {
+				original.Update()
+			}
+
+			snap := original.Snapshot()
+			decoded := roundTrip(t, snap, format)
+			if !reflect.DeepEqual(decoded, snap) {
+				t.Fatalf("Snapshot changed in %s:\n%+v\n%+v", format, snap, decoded)
+			}
+
+			var resumed wator.Wator
+			if err := resumed.Restore(decoded); err != nil {
+				t.Fatal(err)
+			}
+			if resumed.Params() != original.Params() || resumed.Stats() != original.Stats() {
+				t.Errorf("Expected the params and stats to be restored")
+			}
+
+			for i := 0; i < 50; i++ {
+				a, b := original.Update(), resumed.Update()
+				if !reflect.DeepEqual(a, b) {
+					t.Fatalf("Chronon %d: resumed world diverged", original.Chronon)
+				}
+			}
+			if !reflect.DeepEqual(original.Snapshot(), resumed.Snapshot()) {
+				t.Error("Expected the same state after 50 chronons")
+			}
+		})
+	}
+}
+
+func TestSnapshotBinaryIsCompact(t *testing.T) {
+	world := wator.Wator{Seed: 3}
+	if err := world.Init(50, 50, 800, 100, 3, 6, 4); err != nil {
+		t.Fatal(err)
+	}
+	snap := world.Snapshot()
+	data, err := snap.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var js bytes.Buffer
+	snap.WriteJSON(&js)
+	if len(data)*5 > js.Len() {
+		t.Errorf("Expected binary (%d bytes) to be much smaller than JSON (%d bytes)", len(data), js.Len())
+	}
+}
+
+func TestRestoreErrors(t *testing.T) {
+	var world wator.Wator
+	if err := world.Init(3, 3, 2, 1, 3, 3, 2); err != nil {
+		t.Fatal(err)
+	}
+	valid := world.Snapshot()
+
+	tests := []struct {
+		name   string
+		modify func(*wator.Snapshot)
+	}{
+		{"version", func(s *wator.Snapshot) { s.Version = 99 }},
+		{"size", func(s *wator.Snapshot) { s.Params.Width = 0 }},
+		{"too large", func(s *wator.Snapshot) { s.Params.Width, s.Params.Height = wator.MaxCells, 2 }},
+		{"overflow", func(s *wator.Snapshot) { s.Params.Width, s.Params.Height = math.MaxInt, 3 }},
+		{"spawn rate", func(s *wator.Snapshot) { s.Params.FishSpawnRate = 0 }},
+		{"outside", func(s *wator.Snapshot) { s.Creatures[0].Position = 9 }},
+		{"overlap", func(s *wator.Snapshot) { s.Creatures[1].Position = s.Creatures[0].Position }},
+		{"species", func(s *wator.Snapshot) { s.Creatures[0].Species = 7 }},
+		{"rng", func(s *wator.Snapshot) { s.RNG = [4]uint64{} }},
+		{"health", func(s *wator.Snapshot) {
+			for i := range s.Creatures {
+				if s.Creatures[i].Species == wator.SHARK {
+					s.Creatures[i].Health = 0
+				}
+			}
+		}},
+	}
+	for _, tc := range tests {
+		s := valid
+		s.Creatures = append([]wator.Creature{}, valid.Creatures...)
+		tc.modify(&s)
+		var w wator.Wator
+		if err := w.Restore(s); err == nil {
+			t.Errorf("%s: expected Restore to fail", tc.name)
+		}
+	}
+}
+
+func TestReadSnapshotErrors(t *testing.T) {
+	var world wator.Wator
+	if err := world.Init(3, 3, 2, 1, 3, 3, 2); err != nil {
+		t.Fatal(err)
+	}
+	data, err := world.Snapshot().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []string{
+		"",
+		"{not json",
+		`{"version": 5}`,
+		string(data[:len(data)-3]),
+		"WATR\x09",
+	}
+	for _, in := range tests {
+		if _, err := wator.ReadSnapshot(strings.NewReader(in)); err == nil {
+			t.Errorf("ReadSnapshot(%q): expected an error", in)
+		}
+	}
+}
+
+func TestSnapshotVersion1(t *testing.T) {
+	for _, format := range []string{"json", "binary"} {
+		t.Run(format, func(t *testing.T) {
+			world := wator.Wator{Seed: 5}
+			if err := world.Init(8, 8, 10, 3, 3, 6, 4); err != nil {
+				t.Fatal(err)
+			}
+
+			// A version 1 snapshot has no ids.
+			s := world.Snapshot()
+			s.Version, s.LastID = 1, 0
+			for i := range s.Creatures {
+				s.Creatures[i].ID, s.Creatures[i].Parent, s.Creatures[i].Generation = 0, 0, 0
+			}
+			decoded := roundTrip(t, s, format)
+			if !reflect.DeepEqual(decoded, s) {
+				t.Fatalf("Snapshot changed in %s:\n%+v\n%+v", format, s, decoded)
+			}
+
+			var restored wator.Wator
+			if err := restored.Restore(decoded); err != nil {
+				t.Fatal(err)
+			}
+			seen := map[uint64]bool{}
+			for _, c := range restored.Snapshot().Creatures {
+				if c.ID == 0 || seen[c.ID] {
+					t.Errorf("Expected a new unique id, got %d", c.ID)
+				}
+				seen[c.ID] = true
+			}
+		})
+	}
+}
+
+func TestCreatureLineage(t *testing.T) {
+	world := wator.Wator{Seed: 9}
+	if err := world.Init(10, 10, 20, 0, 2, 6, 4); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		world.Update()
+	}
+
+	snap := world.Snapshot()
+	ids := map[uint64]wator.Creature{}
+	for _, c := range snap.Creatures {
+		ids[c.ID] = c
+	}
+	if len(ids) != len(snap.Creatures) {
+		t.Fatalf("Expected %d unique ids, got %d", len(snap.Creatures), len(ids))
+	}
+	spawned := 0
+	for _, c := range snap.Creatures {
+		if c.ID > snap.LastID {
+			t.Errorf("Expected the id %d to be at most the last id %d", c.ID, snap.LastID)
+		}
+		if c.Parent == 0 {
+			if c.Generation != 0 {
+				t.Errorf("Expected generation 0 without a parent, got %d", c.Generation)
+			}
+			continue
+		}
+		spawned++
+		if c.Parent >= c.ID {
+			t.Errorf("Expected the parent %d to be older than %d", c.Parent, c.ID)
+		}
+		if p, ok := ids[c.Parent]; ok && p.Generation+1 != c.Generation {
+			t.Errorf("Expected generation %d, got %d", p.Generation+1, c.Generation)
+		}
+	}
+	if spawned == 0 {
+		t.Error("Expected some fish to have spawned")
+	}
+}
+
+func TestCreatureAndFind(t *testing.T) {
+	world := wator.Wator{Seed: 1}
+	if err := world.Init(3, 3, 0, 0, 3, 5, 2); err != nil {
+		t.Fatal(err)
+	}
+	world.Place(4, wator.SHARK)
+
+	c, ok := world.Creature(4)
+	if !ok || c.Species != wator.SHARK || c.Health != 2 || c.ID == 0 {
+		t.Fatalf("Expected a healthy shark with an id, got %+v %v", c, ok)
+	}
+	if _, ok := world.Creature(0); ok {
+		t.Error("Expected no creature on an empty tile")
+	}
+	if _, ok := world.Creature(9); ok {
+		t.Error("Expected no creature outside of the world")
+	}
+	if s, ok := world.Snapshot().Creature(4); !ok || s != c {
+		t.Errorf("Expected the snapshot to have %+v, got %+v", c, s)
+	}
+
+	world.Update()
+	pos, ok := world.Find(c.ID)
+	if !ok || pos == 4 {
+		t.Errorf("Expected the shark to have moved, got %d %v", pos, ok)
+	}
+	world.Update()
+	if _, ok := world.Find(c.ID); ok {
+		t.Error("Expected the starved shark to be gone")
+	}
+}
+
+func TestNextSpawn(t *testing.T) {
+	p := wator.Params{FishSpawnRate: 3, SharkSpawnRate: 5}
+	tests := []struct {
+		species, age, want int
+	}{
+		{wator.FISH, 0, 4},
+		{wator.FISH, 1, 3},
+		{wator.FISH, 2, 2},
+		{wator.FISH, 3, 1},
+		{wator.FISH, 4, 3},
+		{wator.SHARK, 0, 6},
+		{wator.SHARK, 5, 1},
+		{wator.SHARK, 7, 4},
+	}
+	for _, tc := range tests {
+		c := wator.Creature{Species: tc.species, Age: tc.age}
+		if got := p.NextSpawn(c); got != tc.want {
+			t.Errorf("NextSpawn(%d, age %d): expected %d, got %d", tc.species, tc.age, tc.want, got)
+		}
+	}
+
+	// A fish spawns during the chronon NextSpawn counts.
+	world := wator.Wator{Seed: 2}
+	if err := world.Init(5, 5, 1, 0, 3, 5, 2); err != nil {
+		t.Fatal(err)
+	}
+	c := world.Snapshot().Creatures[0]
+	for i := 1; i < world.Params().NextSpawn(c); i++ {
+		if s := world.Update().Stats; s.FishBorn != 0 {
+			t.Fatalf("Expected no spawn at chronon %d", i)
+		}
+	}
+	if s := world.Update().Stats; s.FishBorn != 1 {
+		t.Errorf("Expected a spawn, got %+v", s)
+	}
+}
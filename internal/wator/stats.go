@@ -0,0 +1,104 @@
+package wator
+
+// Stats summarises the world at the end of a Chronon.  Counts of births and
+// deaths are for the Chronon only while the population figures describe the
+// world after every creature had its turn.
+type Stats struct {
+	Chronon         uint    `json:"chronon"`           // Chronon the statistics are for.
+	Fish            int     `json:"fish"`              // Number of fish alive.
+	Sharks          int     `json:"sharks"`            // Number of sharks alive.
+	FishBorn        int     `json:"fish_born"`         // Fish spawned this chronon.
+	SharksBorn      int     `json:"sharks_born"`       // Sharks spawned this chronon.
+	FishEaten       int     `json:"fish_eaten"`        // Fish that died by being eaten by a shark.
+	SharksStarved   int     `json:"sharks_starved"`    // Sharks that died because they didn't eat.
+	MeanFishAge     float64 `json:"mean_fish_age"`     // Average age of the fish alive.
+	MaxFishAge      int     `json:"max_fish_age"`      // Age of the oldest fish.
+	MeanSharkAge    float64 `json:"mean_shark_age"`    // Average age of the sharks alive.
+	MaxSharkAge     int     `json:"max_shark_age"`     // Age of the oldest shark.
+	MeanSharkHealth float64 `json:"mean_shark_health"` // Average health of the sharks alive.
+	Occupancy       float64 `json:"occupancy"`         // Fraction of the positions holding a creature.
+}
+
+// Deaths returns the number of creatures that died during the chronon.
+func (s Stats) Deaths() int {
+	return s.FishEaten + s.SharksStarved
+}
+
+// Births returns the number of creatures born during the chronon.
+func (s Stats) Births() int {
+	return s.FishBorn + s.SharksBorn
+}
+
+// Stats returns the statistics of the latest Chronon.  Right after Init it
+// describes the initial population.
+func (w *Wator) Stats() Stats {
+
+	if w.stale {
+		w.census(&w.stats)
+	}
+	return w.stats
+}
+
+// count keeps track of the births and deaths of the current chronon.
+func (s *Stats) count(d Delta) {
+
+	switch d.Action {
+	case BIRTH:
+		if d.Object == SHARK {
+			s.SharksBorn++
+		} else {
+			s.FishBorn++
+		}
+	case ATE:
+		s.FishEaten++
+	case DEATH:
+		if d.Object == SHARK {
+			s.SharksStarved++
+		}
+	}
+}
+
+// census returns the snapshot of the world like State and fills in the
+// population figures of the stats in the same pass over the world.
+func (w *Wator) census(stats *Stats) WorldState {
+
+	wm := make([]int, len(w.world))
+	var fishAge, sharkAge, health int
+	stats.Fish, stats.Sharks = 0, 0
+	stats.MaxFishAge, stats.MaxSharkAge = 0, 0
+
+	for i, tile := range w.world {
+		switch c := tile.(type) {
+		case *fish:
+			wm[i] = FISH
+			stats.Fish++
+			fishAge += c.chronon
+			stats.MaxFishAge = max(stats.MaxFishAge, c.chronon)
+		case *shark:
+			wm[i] = SHARK
+			stats.Sharks++
+			sharkAge += c.chronon
+			health += c.health
+			stats.MaxSharkAge = max(stats.MaxSharkAge, c.chronon)
+		default:
+			wm[i] = NONE
+		}
+	}
+
+	stats.MeanFishAge = mean(fishAge, stats.Fish)
+	stats.MeanSharkAge = mean(sharkAge, stats.Sharks)
+	stats.MeanSharkHealth = mean(health, stats.Sharks)
+	stats.Occupancy = mean(stats.Fish+stats.Sharks, len(w.world))
+	w.stale = false
+
+	return wm
+}
+
+// mean returns the average of a total over n items or 0 if there are none.
+func mean(total, n int) float64 {
+
+	if n == 0 {
+		return 0
+	}
+	return float64(total) / float64(n)
+}
@@ -0,0 +1,75 @@
+package wator_test
+
+import (
+	"testing"
+
+	"lazyhacker.dev/wa-tor/internal/wator"
+)
+
+func count(state wator.WorldState) (fish, sharks int) {
+	for _, v := range state {
+		switch v {
+		case wator.FISH:
+			fish++
+		case wator.SHARK:
+			sharks++
+		}
+	}
+	return
+}
+
+func TestStatsAfterInit(t *testing.T) {
+	var world wator.Wator
+	if err := world.Init(4, 5, 6, 3, 3, 3, 2); err != nil {
+		t.Fatalf("Unexpected error during Init: %v", err)
+	}
+	s := world.Stats()
+	if s.Fish != 6 || s.Sharks != 3 {
+		t.Errorf("Expected 6 fish and 3 sharks, got %d and %d", s.Fish, s.Sharks)
+	}
+	if s.Occupancy != 9.0/20.0 {
+		t.Errorf("Expected occupancy %v, got %v", 9.0/20.0, s.Occupancy)
+	}
+	if s.MeanSharkHealth != 2 {
+		t.Errorf("Expected mean shark health 2, got %v", s.MeanSharkHealth)
+	}
+	if s.Births() != 0 || s.Deaths() != 0 {
+		t.Errorf("Expected no births or deaths after Init, got %d and %d", s.Births(), s.Deaths())
+	}
+}
+
+func TestStatsBalance(t *testing.T) {
+	var world wator.Wator
+	if err := world.Init(10, 10, 40, 10, 3, 6, 4); err != nil {
+		t.Fatalf("Unexpected error during Init: %v", err)
+	}
+
+	prev := world.Stats()
+	for i := 0; i < 50; i++ {
+		states := world.Update()
+		s := states.Stats
+		if s != world.Stats() {
+			t.Fatalf("Chronon %d: Update and Stats() disagree", s.Chronon)
+		}
+		if s.Chronon != world.Chronon {
+			t.Errorf("Expected stats for chronon %d, got %d", world.Chronon, s.Chronon)
+		}
+		fish, sharks := count(states.Current)
+		if s.Fish != fish || s.Sharks != sharks {
+			t.Errorf("Chronon %d: stats count %d/%d, state has %d/%d", s.Chronon, s.Fish, s.Sharks, fish, sharks)
+		}
+		if want := prev.Fish + s.FishBorn - s.FishEaten; s.Fish != want {
+			t.Errorf("Chronon %d: expected %d fish from births and deaths, got %d", s.Chronon, want, s.Fish)
+		}
+		if want := prev.Sharks + s.SharksBorn - s.SharksStarved; s.Sharks != want {
+			t.Errorf("Chronon %d: expected %d sharks from births and deaths, got %d", s.Chronon, want, s.Sharks)
+		}
+		if s.MeanSharkHealth > 4 {
+			t.Errorf("Chronon %d: mean shark health %v is above the maximum", s.Chronon, s.MeanSharkHealth)
+		}
+		if s.MaxFishAge > int(s.Chronon) || float64(s.MaxFishAge) < s.MeanFishAge {
+			t.Errorf("Chronon %d: fish ages mean %v max %d are inconsistent", s.Chronon, s.MeanFishAge, s.MaxFishAge)
+		}
+		prev = s
+	}
+}
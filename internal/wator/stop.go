@@ -0,0 +1,240 @@
+package wator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+)
+
+// StopCondition decides whether a run should end after a chronon.  It returns
+// the reason the run ends or an empty string to keep going.  Conditions may
+// remember what they saw in earlier chronons so a new run needs new ones.
+type StopCondition func(w *Wator, s Stats) string
+
+// CheckStop returns the reason given by the first condition that ends the run
+// or an empty string if none does.
+func CheckStop(w *Wator, s Stats, conditions ...StopCondition) string {
+
+	for _, stop := range conditions {
+		if reason := stop(w, s); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+// Extinction ends the run when the fish or the sharks died out.
+func Extinction() StopCondition {
+
+	return func(w *Wator, s Stats) string {
+		switch {
+		case s.Fish == 0 && s.Sharks == 0:
+			return "all creatures extinct"
+		case s.Fish == 0:
+			return "fish extinct"
+		case s.Sharks == 0:
+			return "sharks extinct"
+		}
+		return ""
+	}
+}
+
+// FullOcean ends the run when every position of the world is taken.
+func FullOcean() StopCondition {
+
+	return func(w *Wator, s Stats) string {
+		if s.Occupancy >= 1 {
+			return "ocean full"
+		}
+		return ""
+	}
+}
+
+// Repetition ends the run when the ocean is in exactly the same state as in
+// an earlier chronon: the same species on every position, with the same
+// health for the sharks and the same age as far as spawning goes, that is
+// the age modulo the spawn rate and whether the creature is new born.  The
+// states are told apart by a hash and a hit is confirmed by comparing the
+// whole state.  Worlds with more than maxCells positions are not checked
+// because they practically never repeat and remembering their states would
+// take too much memory.  Only the states of the latest chronons that fit in
+// repetitionMemory bytes are remembered.
+func Repetition(maxCells int) StopCondition {
+
+	type seenState struct {
+		chronon uint
+		state   []byte
+	}
+	seen := make(map[uint64][]seenState)
+	var order []uint64 // Hashes of the remembered states, oldest first.
+	size := 0
+
+	return func(w *Wator, s Stats) string {
+		if len(w.world) > maxCells {
+			return ""
+		}
+
+		state := w.exactState()
+		h := fnv.New64a()
+		h.Write(state)
+		sum := h.Sum64()
+
+		for _, e := range seen[sum] {
+			if bytes.Equal(e.state, state) {
+				return fmt.Sprintf("state of chronon %d repeated", e.chronon)
+			}
+		}
+		seen[sum] = append(seen[sum], seenState{s.Chronon, state})
+		order = append(order, sum)
+		size += len(state)
+
+		for size > repetitionMemory && len(order) > 1 {
+			oldest := seen[order[0]]
+			size -= len(oldest[0].state)
+			if len(oldest) == 1 {
+				delete(seen, order[0])
+			} else {
+				seen[order[0]] = oldest[1:]
+			}
+			order = order[1:]
+		}
+		return ""
+	}
+}
+
+// repetitionMemory is the number of bytes of states Repetition remembers at
+// most.
+const repetitionMemory = 64 << 20
+
+// exactState encodes everything about the ocean that decides how it carries
+// on: the species on each position, the age of each creature as far as
+// spawning goes and the health of each shark.
+func (w *Wator) exactState() []byte {
+
+	// 0 is a new born creature, which doesn't spawn, and 1 to rate the
+	// chronons since it was born or last could spawn.
+	spawnAge := func(age, rate int) uint64 {
+		if age <= 0 || rate <= 0 {
+			return 0
+		}
+		return uint64((age-1)%rate + 1)
+	}
+
+	state := make([]byte, 0, 3*len(w.world))
+	for _, c := range w.world {
+		switch c := c.(type) {
+		case *fish:
+			state = append(state, byte(FISH))
+			state = binary.AppendUvarint(state, spawnAge(c.age(), w.fishSpawnRate))
+		case *shark:
+			state = append(state, byte(SHARK))
+			state = binary.AppendUvarint(state, spawnAge(c.age(), w.sharkSpawnRate))
+			state = binary.AppendVarint(state, int64(c.health))
+		default:
+			state = append(state, byte(NONE))
+		}
+	}
+	return state
+}
+
+// Stationary ends the run when the populations settled into a steady state
+// or a steady oscillation.  The mean and standard deviation of the fish and
+// shark counts over the last window chronons are compared to the window
+// before it and the run ends when none changed by more than the tolerance,
+// a fraction of the earlier value.
+func Stationary(window int, tolerance float64) StopCondition {
+
+	var fish, sharks []float64
+
+	return func(w *Wator, s Stats) string {
+		fish = append(fish, float64(s.Fish))
+		sharks = append(sharks, float64(s.Sharks))
+		if len(fish) > 2*window {
+			fish = fish[1:]
+			sharks = sharks[1:]
+		}
+		if len(fish) < 2*window {
+			return ""
+		}
+
+		if settled(fish[:window], fish[window:], tolerance) &&
+			settled(sharks[:window], sharks[window:], tolerance) {
+			return fmt.Sprintf("populations stationary over the last %d chronons", 2*window)
+		}
+		return ""
+	}
+}
+
+// settled reports whether the mean and standard deviation of two windows of
+// a series are within the tolerance of each other.
+func settled(before, after []float64, tolerance float64) bool {
+
+	m1, sd1 := meanStdDev(before)
+	m2, sd2 := meanStdDev(after)
+
+	return within(m1, m2, tolerance) && within(sd1, sd2, tolerance)
+}
+
+// within reports whether b differs from a by at most the fraction tolerance
+// of a.  Values very close to zero compare absolutely.
+func within(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance*math.Max(math.Abs(a), 1)
+}
+
+// meanStdDev returns the mean and the standard deviation of the values.
+func meanStdDev(values []float64) (float64, float64) {
+
+	var sum, sq float64
+	for _, v := range values {
+		sum += v
+	}
+	m := sum / float64(len(values))
+	for _, v := range values {
+		sq += (v - m) * (v - m)
+	}
+	return m, math.Sqrt(sq / float64(len(values)))
+}
+
+// Default settings of the detectors created by ParseStopConditions.
+const (
+	RepetitionMaxCells  = 4096
+	StationaryWindow    = 500
+	StationaryTolerance = 0.05
+)
+
+// ParseStopConditions creates the conditions named in a comma separated list
+// of detectors (extinction, full, repeat and stationary) followed by the
+// condition of the expression, if any.  See ParseStopExpr for the syntax of
+// the expression.
+func ParseStopConditions(detectors, expr string) ([]StopCondition, error) {
+
+	var conditions []StopCondition
+	for _, name := range strings.Split(detectors, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case "extinction":
+			conditions = append(conditions, Extinction())
+		case "full":
+			conditions = append(conditions, FullOcean())
+		case "repeat":
+			conditions = append(conditions, Repetition(RepetitionMaxCells))
+		case "stationary":
+			conditions = append(conditions, Stationary(StationaryWindow, StationaryTolerance))
+		default:
+			return nil, fmt.Errorf("Unknown stop condition %q.", name)
+		}
+	}
+
+	if strings.TrimSpace(expr) != "" {
+		stop, err := ParseStopExpr(expr)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, stop)
+	}
+
+	return conditions, nil
+}
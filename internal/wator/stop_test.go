@@ -0,0 +1,184 @@
+package wator_test
+
+import (
+	"strings"
+	"testing"
+
+	"lazyhacker.dev/wa-tor/internal/wator"
+)
+
+func TestParseStopExpr(t *testing.T) {
+	tests := []struct {
+		expr  string
+		stats wator.Stats
+		want  bool
+	}{
+		{"sharks < 5 or chronon > 10000", wator.Stats{Sharks: 4, Chronon: 10}, true},
+		{"sharks < 5 or chronon > 10000", wator.Stats{Sharks: 5, Chronon: 10}, false},
+		{"sharks < 5 or chronon > 10000", wator.Stats{Sharks: 9, Chronon: 10001}, true},
+		{"fish>=100&&sharks<=2", wator.Stats{Fish: 100, Sharks: 2}, true},
+		{"fish>=100&&sharks<=2", wator.Stats{Fish: 99, Sharks: 2}, false},
+		{"occupancy == 1", wator.Stats{Occupancy: 1}, true},
+		{"deaths != 0", wator.Stats{FishEaten: 1}, true},
+		{"(fish < 1 or sharks < 1) and chronon > 5", wator.Stats{Fish: 0, Chronon: 3}, false},
+		{"(fish < 1 or sharks < 1) and chronon > 5", wator.Stats{Fish: 0, Chronon: 6}, true},
+		{"fish < 1 or sharks < 1 and chronon > 5", wator.Stats{Fish: 0, Chronon: 3}, true},
+		{"mean_shark_health < -1", wator.Stats{MeanSharkHealth: -2}, true},
+	}
+
+	for _, tc := range tests {
+		stop, err := wator.ParseStopExpr(tc.expr)
+		if err != nil {
+			t.Errorf("ParseStopExpr(%q): %v", tc.expr, err)
+			continue
+		}
+		if got := stop(nil, tc.stats) != ""; got != tc.want {
+			t.Errorf("%q with %+v = %v, expected %v", tc.expr, tc.stats, got, tc.want)
+		}
+	}
+}
+
+func TestParseStopExprErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"whales < 5",
+		"sharks ~ 5",
+		"sharks < five",
+		"sharks <",
+		"(sharks < 5",
+		"sharks < 5 fish > 2",
+		"sharks < 5 or",
+	}
+	for _, expr := range tests {
+		if _, err := wator.ParseStopExpr(expr); err == nil {
+			t.Errorf("ParseStopExpr(%q): expected an error", expr)
+		}
+	}
+}
+
+func TestParseStopConditions(t *testing.T) {
+	conds, err := wator.ParseStopConditions("extinction, full,repeat,stationary", "chronon > 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conds) != 5 {
+		t.Errorf("Expected 5 conditions, got %d", len(conds))
+	}
+	if _, err := wator.ParseStopConditions("forever", ""); err == nil {
+		t.Error("Expected an error for an unknown condition")
+	}
+}
+
+func TestExtinction(t *testing.T) {
+	stop := wator.Extinction()
+	tests := []struct {
+		stats wator.Stats
+		want  string
+	}{
+		{wator.Stats{Fish: 1, Sharks: 1}, ""},
+		{wator.Stats{Fish: 0, Sharks: 1}, "fish extinct"},
+		{wator.Stats{Fish: 1, Sharks: 0}, "sharks extinct"},
+		{wator.Stats{}, "all creatures extinct"},
+	}
+	for _, tc := range tests {
+		if got := stop(nil, tc.stats); got != tc.want {
+			t.Errorf("Extinction with %+v = %q, expected %q", tc.stats, got, tc.want)
+		}
+	}
+}
+
+func TestFullOcean(t *testing.T) {
+	// Fish spawning every chronon without sharks fill a small world.
+	world := wator.Wator{Seed: 3}
+	if err := world.Init(3, 3, 1, 0, 1, 3, 2); err != nil {
+		t.Fatal(err)
+	}
+	stop := wator.FullOcean()
+	for i := 0; i < 100; i++ {
+		s := world.Update().Stats
+		if reason := wator.CheckStop(&world, s, stop); reason != "" {
+			if s.Fish != 9 {
+				t.Errorf("Ocean reported full with %d fish", s.Fish)
+			}
+			return
+		}
+	}
+	t.Error("Expected the ocean to fill up")
+}
+
+func TestRepetition(t *testing.T) {
+	// A full ocean of fish can't move, so only the ages of the fish change
+	// and they repeat every spawn rate chronons.
+	world := wator.Wator{Seed: 3}
+	if err := world.Init(2, 2, 4, 0, 3, 3, 2); err != nil {
+		t.Fatal(err)
+	}
+	stop := wator.Repetition(16)
+	if reason := stop(&world, world.Stats()); reason != "" {
+		t.Fatalf("Unexpected stop on the first state: %s", reason)
+	}
+	var s wator.Stats
+	var reason string
+	for reason == "" && world.Chronon < 10 {
+		s = world.Update().Stats
+		reason = stop(&world, s)
+		if reason != "" && s.Chronon < 4 {
+			t.Fatalf("Chronon %d: the ages were ignored, got %q", s.Chronon, reason)
+		}
+	}
+	if reason != "state of chronon 1 repeated" {
+		t.Errorf("Expected the state of chronon 1 to repeat, got %q", reason)
+	}
+
+	// Large worlds are not checked.
+	stop = wator.Repetition(3)
+	stop(&world, s)
+	if reason := stop(&world, s); reason != "" {
+		t.Errorf("Expected a world larger than the limit to be ignored, got %q", reason)
+	}
+}
+
+func TestRepetitionHealth(t *testing.T) {
+	world := wator.Wator{Seed: 3}
+	if err := world.Init(2, 2, 1, 1, 3, 3, 2); err != nil {
+		t.Fatal(err)
+	}
+	snap := world.Snapshot()
+	stop := wator.Repetition(16)
+	stop(&world, wator.Stats{Chronon: 0})
+
+	// The same positions and ages with another health is another state.
+	for i, c := range snap.Creatures {
+		if c.Species == wator.SHARK {
+			snap.Creatures[i].Health--
+		}
+	}
+	if err := world.Restore(snap); err != nil {
+		t.Fatal(err)
+	}
+	if reason := stop(&world, wator.Stats{Chronon: 1}); reason != "" {
+		t.Errorf("Expected the health of the sharks to be compared, got %q", reason)
+	}
+	if reason := stop(&world, wator.Stats{Chronon: 2}); reason != "state of chronon 1 repeated" {
+		t.Errorf("Expected the state of chronon 1 to repeat, got %q", reason)
+	}
+}
+
+func TestStationary(t *testing.T) {
+	stop := wator.Stationary(10, 0.05)
+	var reason string
+	// A regular oscillation with a period dividing the window.
+	for c := uint(0); c < 20 && reason == ""; c++ {
+		reason = stop(nil, wator.Stats{Chronon: c, Fish: 100 + int(c%5)*10, Sharks: 20 + int(c%5)})
+	}
+	if !strings.HasPrefix(reason, "populations stationary") {
+		t.Errorf("Expected a stationary oscillation to be detected, got %q", reason)
+	}
+
+	stop = wator.Stationary(10, 0.05)
+	for c := uint(0); c < 40; c++ {
+		if reason := stop(nil, wator.Stats{Chronon: c, Fish: int(c * c), Sharks: 5}); reason != "" {
+			t.Fatalf("Chronon %d: growing population reported as stationary", c)
+		}
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"fmt"
 	"log"
 	"math/rand"
+	"time"
 )
 
 const (
@@ -27,10 +28,15 @@ type WorldState []int
 
 // WorldStates contains the positions of every fish and shark on the map.
 // The index is the position and the value is NONE, FISH, or SHARK.
+//
+// The ChangeLog is in the order the changes were made to the world.  A shark
+// that eats records ATE before its move and a newborn is recorded after the
+// move of its parent, at the position the parent left.
 type WorldStates struct {
 	Previous  WorldState // Position of Fishes/Shark previous chronon.
 	Current   WorldState // Position of Fishes/Shark in current chronon.
 	ChangeLog []Delta    // List of changes between Chronon.
+	Stats     Stats      // Statistics of the current chronon.
 }
 
 const (
@@ -53,18 +59,12 @@ const (
 	SOUTH
 )
 
-var (
-	fishSpawnRate  int
-	sharkSpawnRate int
-	sharkHealth    int
-)
-
 // worlditem is what is at a location on the world map.  This is generally
 // a creature or nothing at all.
 type worldItem interface {
 	age() int
 	setAge(int)
-	spawn() bool
+	spawn(rate int) bool
 	lastMove() uint
 	setLastMove(uint)
 }
@@ -72,42 +72,129 @@ type worldItem interface {
 // Wator represents the world of Wa-tor, a toroidal (donut-shaped) sea planet
 // consisting of fish and sharks.
 type Wator struct {
-	world          []worldItem // Game map is a NxM but represented linearly.
-	Width, Height  int         // Dimension of the world.
-	Chronon        uint        // Age of the world
-	fishSpawnRate  int         // Chronon for a fish to spawn a new fish
-	sharkSpawnRate int         // Chronon for a shark to spawn a new shark
+	world          []worldItem     // Game map is a NxM but represented linearly.
+	Width, Height  int             // Dimension of the world.
+	Chronon        uint            // Age of the world
+	Seed           int64           // Seed of the world's randomness, 0 picks one at Init.
+	fishSpawnRate  int             // Chronon for a fish to spawn a new fish
+	sharkSpawnRate int             // Chronon for a shark to spawn a new shark
+	sharkHealth    int             // Chronon a shark can go without eating
+	numFish        int             // Initial number of fish
+	numSharks      int             // Initial number of sharks
+	src            *source         // State of the randomness for this world.
+	rng            *rand.Rand      // Random numbers drawn from src.
+	observers      []*subscription // Observers notified during Update.
+	stats          Stats           // Statistics of the latest chronon.
+	stale          bool            // Creatures were placed since stats was counted.
+	lastID         uint64          // id of the latest creature.
+}
+
+// Params are the settings a world was initialized with.
+type Params struct {
+	Width          int   `json:"width"`
+	Height         int   `json:"height"`
+	Fish           int   `json:"fish"`   // Initial number of fish.
+	Sharks         int   `json:"sharks"` // Initial number of sharks.
+	FishSpawnRate  int   `json:"fish_spawn_rate"`
+	SharkSpawnRate int   `json:"shark_spawn_rate"`
+	Health         int   `json:"health"`
+	Seed           int64 `json:"seed"`
+}
+
+// String returns the parameters as space separated key=value pairs.
+func (p Params) String() string {
+	return fmt.Sprintf("width=%d height=%d fish=%d sharks=%d fish-spawn-rate=%d shark-spawn-rate=%d health=%d seed=%d",
+		p.Width, p.Height, p.Fish, p.Sharks, p.FishSpawnRate, p.SharkSpawnRate, p.Health, p.Seed)
+}
+
+// Params returns the settings of the world.
+func (w *Wator) Params() Params {
+	return Params{
+		Width:          w.Width,
+		Height:         w.Height,
+		Fish:           w.numFish,
+		Sharks:         w.numSharks,
+		FishSpawnRate:  w.fishSpawnRate,
+		SharkSpawnRate: w.sharkSpawnRate,
+		Health:         w.sharkHealth,
+		Seed:           w.Seed,
+	}
+}
+
+// MaxCells is the largest number of positions a world can have.  The world
+// keeps every position in memory so a larger one would not fit.
+const MaxCells = 1 << 24
+
+// tooLarge reports whether a world of width by height positions has more than
+// MaxCells of them, without overflowing.  Both must be positive.
+func tooLarge(width, height int) bool {
+	return width > MaxCells/height
+}
+
+// Validate returns an error if Init refuses to set up a world with the
+// parameters.  The seed can be anything.
+func (p Params) Validate() error {
+
+	if p.Width <= 0 || p.Height <= 0 {
+		return fmt.Errorf("Width and height must be positive.")
+	}
+	if tooLarge(p.Width, p.Height) {
+		return fmt.Errorf("World of %dx%d has more than %d positions.", p.Width, p.Height, MaxCells)
+	}
+	if p.Fish < 0 || p.Sharks < 0 {
+		return fmt.Errorf("Number of fish and sharks can't be negative.")
+	}
+	if p.FishSpawnRate <= 0 || p.SharkSpawnRate <= 0 || p.Health <= 0 {
+		return fmt.Errorf("Spawn rates and health must be positive.")
+	}
+	if p.Fish > p.Width*p.Height-p.Sharks {
+		return fmt.Errorf("Too many creatures to fit on map!")
+	}
+
+	// If sharks spawns faster then health meter drop rate the popluataion
+	// will never decrease.
+	if p.Health > p.SharkSpawnRate {
+		return fmt.Errorf("Health meter needs to be less than the Shark spawn rate.")
+	}
+	return nil
 }
 
 // Init will set up the world and populate the initial set of fish and shark
 // at random positions in the world.  fsr and ssr are the rate by which fish
 // and sharks will spawn a new born.  health is the number of Chronon before
 // a shark dies if it hasn't eaten a fish.
+//
+// The randomness of the world comes from w.Seed so that worlds initialized
+// with the same seed and parameters evolve identically.  If Seed is 0, one is
+// picked and stored in w.Seed.
 func (w *Wator) Init(width, height, numfish, numsharks, fsr, ssr, health int) error {
 
 	w.Width = width
 	w.Height = height
-	fishSpawnRate = fsr
-	sharkSpawnRate = ssr
-	sharkHealth = health
-
-	mapSize := w.Width * w.Height
-	if numfish+numsharks > mapSize {
-		return fmt.Errorf("Too many creatures to fit on map!")
+	w.fishSpawnRate = fsr
+	w.sharkSpawnRate = ssr
+	w.sharkHealth = health
+	w.numFish = numfish
+	w.numSharks = numsharks
+
+	if w.Seed == 0 {
+		w.Seed = time.Now().UnixNano()
 	}
+	w.src = newSource(w.Seed)
+	w.rng = rand.New(w.src)
 
-	// If sharks spawns faster then health meter drop rate the popluataion
-	// will never decrease.
-	if health > ssr {
-		return fmt.Errorf("Health meter needs to be less than the Shark spawn rate.")
+	if err := w.Params().Validate(); err != nil {
+		return err
 	}
+	mapSize := w.Width * w.Height
 
 	// Have a sequence of numbers that will get randomnized to determine
 	// where to initially seed the world.
 	sequence := sequence{}
-	sequence.init(mapSize)
+	sequence.init(mapSize, w.rng)
 
 	w.world = make([]worldItem, mapSize)
+	w.lastID = 0
 
 	// seed fishes on the tile map.
 	for i := 0; i < numfish; i++ {
@@ -118,7 +205,7 @@ func (w *Wator) Init(width, height, numfish, numsharks, fsr, ssr, health int) er
 		}
 
 		p := sequence.next()
-		w.world[p] = NewFish()
+		w.world[p] = w.newFish(nil)
 	}
 
 	// seed the sharks on the tile map.
@@ -130,12 +217,70 @@ func (w *Wator) Init(width, height, numfish, numsharks, fsr, ssr, health int) er
 		}
 
 		p := sequence.next()
-		w.world[p] = NewShark()
+		w.world[p] = w.newShark(nil)
+	}
+
+	w.stats = Stats{Chronon: w.Chronon}
+	w.census(&w.stats)
+
+	return nil
+}
+
+// SetRules changes the spawn rates and the health of the sharks of a running
+// world.  The new rules apply from the next Update.  Sharks healthier than
+// the new health are limited to it.
+func (w *Wator) SetRules(fsr, ssr, health int) error {
+
+	if fsr <= 0 || ssr <= 0 || health <= 0 {
+		return fmt.Errorf("Spawn rates and health must be positive.")
+	}
+	if health > ssr {
+		return fmt.Errorf("Health meter needs to be less than the Shark spawn rate.")
+	}
+
+	w.fishSpawnRate = fsr
+	w.sharkSpawnRate = ssr
+	w.sharkHealth = health
+	for _, tile := range w.world {
+		if s, ok := tile.(*shark); ok {
+			s.health = min(s.health, health)
+		}
 	}
 
 	return nil
 }
 
+// Place puts a new creature of the species at the position, replacing what
+// was there.  NONE empties the position.  A creature of the same species
+// already there is kept.  New fish are newborn and new sharks have full
+// health.  Placing is not part of a chronon so observers are not notified.
+// The population is counted again when the statistics are next asked for,
+// so that painting many positions counts it once.
+func (w *Wator) Place(pos, species int) error {
+
+	if pos < 0 || pos >= len(w.world) {
+		return fmt.Errorf("Position %d is outside the world.", pos)
+	}
+
+	switch species {
+	case NONE:
+		w.world[pos] = nil
+	case FISH:
+		if _, ok := w.world[pos].(*fish); !ok {
+			w.world[pos] = w.newFish(nil)
+		}
+	case SHARK:
+		if _, ok := w.world[pos].(*shark); !ok {
+			w.world[pos] = w.newShark(nil)
+		}
+	default:
+		return fmt.Errorf("Unknown species %d.", species)
+	}
+	w.stale = true
+
+	return nil
+}
+
 // Update advances the world by 1 Chronon.  During each Chronon:
 //   - Fish feed on ubiuitous plankton and the sharks feed on the fish.
 //   - Fish move randomly to an unoccupied adjacent square.
@@ -148,8 +293,13 @@ func (w *Wator) Update() WorldStates {
 
 	prev := w.State()
 	w.Chronon++
+	w.stats = Stats{Chronon: w.Chronon}
 	var delta []Delta
 
+	for _, s := range w.observers {
+		s.observer.OnChrononStart(w)
+	}
+
 	for i, tile := range w.world {
 		if tile == nil {
 			continue
@@ -164,63 +314,77 @@ func (w *Wator) Update() WorldStates {
 
 		// find adjacent positions
 		adjacents := w.adjacentList(i)
-		newPos := i
+
+		// Every change is applied to the world before it is recorded so
+		// that the changelog can be replayed in order and observers see
+		// the world as the change describes it.
 		switch c := tile.(type) {
 		case *fish:
-			var f *fish
 
 			// Return fish movement and if it spawned a new fish.
-			newPos, f = w.fishTurn(c, i, adjacents)
+			newPos, f := w.fishTurn(c, i, adjacents)
+			tile.setAge(tile.age() + 1)
 
+			w.moveCreature(i, newPos)
+			w.recordChange(&delta, FISH, i, newPos, MOVE)
+
+			// The spawn is left behind in the position the fish moved
+			// from.
 			if f != nil {
-				// Put the spawn at the new position because it will
-				// then get swapped before the turn is completed and
-				// end up in the current position.
-				w.world[newPos] = f
-				w.world[newPos].setLastMove(w.Chronon)
+				w.world[i] = f
+				w.world[i].setLastMove(w.Chronon)
 				w.recordChange(&delta, FISH, i, i, BIRTH)
 			}
-			w.recordChange(&delta, FISH, i, newPos, MOVE)
 
 		case *shark:
 
-			var s *shark
-			var alive bool
-			alive, newPos, s = w.sharkTurn(c, i, adjacents)
+			alive, newPos, s := w.sharkTurn(c, i, adjacents)
 			// If shark doesn't eat, it dies.
 			if !alive {
 				w.world[i] = nil
 				w.recordChange(&delta, SHARK, i, i, DEATH)
 				continue
 			}
-
-			w.recordChange(&delta, SHARK, i, newPos, MOVE)
+			tile.setAge(tile.age() + 1)
 
 			if _, ok := w.world[newPos].(*fish); ok {
 				w.world[newPos] = nil
 				w.recordChange(&delta, SHARK, i, newPos, ATE)
 			}
+
+			w.moveCreature(i, newPos)
+			w.recordChange(&delta, SHARK, i, newPos, MOVE)
+
 			if s != nil {
-				w.world[newPos] = s
-				w.world[newPos].setLastMove(w.Chronon)
+				w.world[i] = s
+				w.world[i].setLastMove(w.Chronon)
 				w.recordChange(&delta, SHARK, i, i, BIRTH)
 			}
 		}
-
-		if newPos != i {
-			// Move the creature by swapping its current location with new position
-			w.world[newPos], w.world[i] = w.world[i], w.world[newPos]
-		}
-
-		tile.setAge(tile.age() + 1)
 	}
 
-	current := w.State()
+	current := w.census(&w.stats)
 
-	return WorldStates{
+	states := WorldStates{
 		Previous:  prev,
 		Current:   current,
 		ChangeLog: delta,
+		Stats:     w.stats,
+	}
+
+	for _, s := range w.observers {
+		s.observer.OnChrononEnd(w, states)
+	}
+
+	return states
+}
+
+// moveCreature moves the creature by swapping its current location with the
+// new position.
+func (w *Wator) moveCreature(from, to int) {
+
+	if from != to {
+		w.world[to], w.world[from] = w.world[from], w.world[to]
 	}
 }
 
@@ -228,10 +392,10 @@ func (w *Wator) Update() WorldStates {
 // and if it spawned a new fish.
 func (w *Wator) fishTurn(fish *fish, pos int, adjacents []int) (int, *fish) {
 
-	newPos := fish.move(pos, w.world, adjacents)
+	newPos := fish.move(pos, w.world, adjacents, w.random())
 	fish.direction = w.direction(pos, newPos)
-	if fish.spawn() && newPos != pos {
-		return newPos, NewFish()
+	if fish.spawn(w.fishSpawnRate) && newPos != pos {
+		return newPos, w.newFish(&fish.creature)
 	}
 
 	return newPos, nil
@@ -241,39 +405,93 @@ func (w *Wator) fishTurn(fish *fish, pos int, adjacents []int) (int, *fish) {
 // sharkTurn handles a shark's behavior each turn.
 func (w *Wator) sharkTurn(shark *shark, pos int, adjacents []int) (bool, int, *shark) {
 	// If shark doesn't eat, it dies.
-	if shark.starve() == 0 {
+	if shark.starve() <= 0 {
 		return false, pos, nil
 	}
 
-	newPos := shark.move(pos, w.world, adjacents)
+	newPos := shark.move(pos, w.world, adjacents, w.random())
 	shark.direction = w.direction(pos, newPos)
 	if _, ok := w.world[newPos].(*fish); ok {
-		shark.feed()
+		shark.feed(w.sharkHealth)
 	}
 
 	// Cannot spawn if no open space.
-	if shark.spawn() && newPos != pos {
-		return true, newPos, NewShark()
+	if shark.spawn(w.sharkSpawnRate) && newPos != pos {
+		return true, newPos, w.newShark(&shark.creature)
 	}
 
 	return true, newPos, nil
 
 }
 
-// recordChange adds a change to the changelog and uses strings for animals and
-// actions instead of the numeric values.
+// newFish returns a new fish of this world spawned by the parent, nil for a
+// fish without one.
+func (w *Wator) newFish(parent *creature) *fish {
+
+	f := NewFish()
+	w.adopt(&f.creature, parent)
+	return f
+}
+
+// newShark returns a new shark with the full health of this world spawned
+// by the parent, nil for a shark without one.
+func (w *Wator) newShark(parent *creature) *shark {
+
+	s := NewShark(w.sharkHealth)
+	w.adopt(&s.creature, parent)
+	return s
+}
+
+// adopt gives the creature the next id of the world and records its
+// parent.
+func (w *Wator) adopt(c *creature, parent *creature) {
+
+	w.lastID++
+	c.id = w.lastID
+	if parent != nil {
+		c.parent = parent.id
+		c.generation = parent.generation + 1
+	}
+}
+
+// Reseed replaces the randomness of the world with one seeded by seed and
+// stores it in w.Seed.  The world then evolves differently from the same
+// state, which branches a new future from a restored snapshot.
+func (w *Wator) Reseed(seed int64) {
+
+	w.Seed = seed
+	w.src = newSource(seed)
+	w.rng = rand.New(w.src)
+}
+
+// random returns the source of randomness of the world.  A world that was
+// never initialized gets a randomly seeded one.
+func (w *Wator) random() *rand.Rand {
+
+	if w.rng == nil {
+		w.src = newSource(time.Now().UnixNano())
+		w.rng = rand.New(w.src)
+	}
+	return w.rng
+}
+
+// recordChange adds a change to the changelog and notifies the observers.
+// MOVE is recorded as the direction of the movement.
 func (w *Wator) recordChange(changelog *[]Delta, animal, from, to, action int) {
 
 	if action == MOVE {
 		action = w.direction(from, to)
 	}
 
-	*changelog = append(*changelog, Delta{
+	d := Delta{
 		Object: animal,
 		From:   from,
 		To:     to,
 		Action: action,
-	})
+	}
+	*changelog = append(*changelog, d)
+	w.stats.count(d)
+	w.notify(d)
 }
 
 // State returns the snapshop of where each fish and shark is at on the map.
@@ -339,10 +557,7 @@ func (w *Wator) adjacents(pos int) (up, down, left, right int) {
 // pickPosition randomly picks the element from the given slice.
 func (w *Wator) pickPosition(curr int, numbers []int) int {
 
-	if len(numbers) == 0 {
-		return curr
-	}
-	return numbers[rand.Intn(len(numbers))]
+	return pickPosition(w.random(), curr, numbers)
 }
 
 // direction returns the relative direction of the end position to the start
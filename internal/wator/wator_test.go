@@ -2,6 +2,7 @@ package wator
 
 import (
 	"fmt"
+	"math"
 	"testing"
 )
 
@@ -17,6 +18,12 @@ func TestInit(t *testing.T) {
 		{"Too many creatures", 5, 5, 20, 10, 3, 3, 2, true},
 		{"Health error", 5, 5, 5, 5, 5, 5, 10, true},
 		{"Valid init", 5, 5, 5, 5, 3, 3, 2, false},
+		{"Empty world", 0, 5, 0, 0, 3, 3, 2, true},
+		{"Negative fish", 5, 5, -1, 5, 3, 3, 2, true},
+		{"Zero spawn rate", 5, 5, 5, 5, 0, 3, 2, true},
+		{"Too many positions", MaxCells + 1, 1, 0, 0, 3, 3, 2, true},
+		{"Overflowing size", math.MaxInt, 2, 0, 0, 3, 3, 2, true},
+		{"Overflowing creatures", 5, 5, math.MaxInt, 5, 3, 3, 2, true},
 	}
 
 	for i, tc := range tests {
@@ -107,3 +114,28 @@ func TestPickPosition(t *testing.T) {
 		})
 	}
 }
+
+// TestSharkStarves tests that a shark dies once its health runs out, however
+// it was made.
+func TestSharkStarves(t *testing.T) {
+	tests := []struct {
+		health    int
+		wantAlive bool
+	}{
+		{2, true},
+		{1, false},
+		{0, false},
+	}
+
+	for _, tc := range tests {
+		var w Wator
+		if err := w.Init(3, 3, 0, 0, 3, 5, 2); err != nil {
+			t.Fatal(err)
+		}
+		s := NewShark(tc.health)
+		w.world[4] = s
+		if alive, _, _ := w.sharkTurn(s, 4, w.adjacentList(4)); alive != tc.wantAlive {
+			t.Errorf("Shark with health %d: expected alive %v, got %v", tc.health, tc.wantAlive, alive)
+		}
+	}
+}
@@ -4,13 +4,17 @@
 package main // package lazyhacker.dev/wator
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
 	"image"
 	"image/color"
 	"log"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"golang.org/x/image/font/basicfont"
 	"lazyhacker.dev/wa-tor/internal/wator"
@@ -22,14 +26,15 @@ import (
 )
 
 const (
-	TileSize  = 32 // pixels width/height per tile
-	AniFrames = 8  // number of sprites for animation
+	TileSize    = 32 // pixels width/height per tile
+	AniFrames   = 8  // number of sprites for animation
+	DeathFrames = 5  // number of sprites of the death animation
 
 	// 0-7 :   animation frames going east
 	// 8-15:   animation going west
-	// 16-23:  alternative animation going east
-	// 24-31:  alternative animation going west
-	// 32:     death image
+	// 16-23:  alternative animation going east (sharks eating)
+	// 24-31:  alternative animation going west (sharks eating)
+	// 32-36:  death animation
 	EastStartIdx    = 0
 	EastEndIdx      = 7
 	WestStartIdx    = 8
@@ -38,7 +43,8 @@ const (
 	AltEastEndIdx   = 23
 	AltWestStartIdx = 24
 	AltWestEndIdx   = 31
-	DeathSpriteIdx  = 32
+	DeathStartIdx   = 32
+	DeathEndIdx     = 36
 )
 
 var (
@@ -49,6 +55,17 @@ var (
 	health      = flag.Int("health", 20, "# of cycles shark can go with feeding before dying.")
 	width       = flag.Int("width", 16, "number of tiles horizontally (cols)")
 	height      = flag.Int("height", 12, "number of tiles verticals (rows)")
+	seed        = flag.Int64("seed", 0, "seed of the first world, 0 for a random one")
+	stopOn      = flag.String("stop-on", "", "comma separated detectors ending a run: extinction, full, repeat, stationary")
+	stopExpr    = flag.String("stop", "", `expression ending a run, e.g. "sharks < 5 or chronon > 10000"`)
+	autoRestart = flag.Bool("auto-restart", false, "start a new world with a fresh seed when a run ends")
+	snapshot    = flag.String("snapshot", "wator-snapshot.json", "file Ctrl+S saves the world to and Ctrl+L loads it from, JSON if it ends in .json and binary otherwise")
+	record      = flag.String("record", "", "file to write the replay of the world to, started over with every new world and compressed if it ends in .gz")
+	play        = flag.String("play", "", "replay file to play back instead of running a world")
+	keep        = flag.Int("history", 1000, "number of chronons kept to step back through")
+	keepMB      = flag.Int("history-mb", 64, "megabytes the chronons kept to step back through can use")
+	heatWindow  = flag.Int("heat-window", 100, "number of chronons the overlays count over, fewer for large worlds")
+	trailLength = flag.Int("trail-length", 12, "number of tiles the trails of the creatures cover")
 )
 
 // Frame is a position on the screen corresponding to the position of the Wa-tor
@@ -58,26 +75,52 @@ type Frame struct {
 	tileType  int
 	x, y      float64
 	direction int
+	born      bool    // a new born creature appearing
+	grow      float64 // how far the new born creature appeared, from 0 to 1
 }
 
 // Game holds the game state.  For Ebiten, this needs to be an ebiten.Game
 // interface.
 type Game struct {
-	world            wator.Wator
-	currentScreen    []Frame
-	sharkSprite      []*ebiten.Image
-	fishSprite       []*ebiten.Image
-	pause            bool
-	frames           [][]Frame
-	ctickCounter     int
-	drawFrameCounter int
-	tpsPerChronon    int
-	tpsPerFrame      int
-	pixelsMove       int
-	startFish        int
-	startShark       int
-	width            int
-	height           int
+	world          wator.Wator
+	sharkSprite    []*ebiten.Image
+	fishSprite     []*ebiten.Image
+	pause          bool
+	ctickCounter   int
+	tpsPerChronon  int
+	pixelsMove     int
+	settings       wator.Params // parameters new worlds start with
+	stopConditions []wator.StopCondition
+	ended          string // why the last run ended
+	status         string // message for the user, such as the result of the last save
+	recording      *wator.ReplayWriter
+	anim           animation     // change to the shown chronon being drawn
+	history        *history      // recent chronons of the live world
+	player         *wator.Player // replay being played back, nil for a live world
+	playSpeed      int           // index of playSpeeds during playback
+	scrubbing      bool          // the scrubber is being dragged
+	speed          int           // index of speeds of the live world
+	lastSpeed      int           // speed before switching to turbo mode
+	turboDone      int           // chronons run in the last tick of turbo mode
+	panelOpen      bool          // the parameter panel is shown
+	dragging       int           // index of the slider being dragged or -1
+	panelSprite    *ebiten.Image
+	knobSprite     *ebiten.Image
+	trackSprites   []*ebiten.Image // ends and middle of the empty and the filled track
+	setup          *setupScreen    // new world setup, nil when not shown
+	editor         *editor         // world editor, nil when not editing
+	cam            camera          // part of the world on the screen
+	screenW        int             // size of the screen in pixels
+	screenH        int
+	renderer       int      // index of renderNames
+	pixels         pixelMap // world drawn as pixels
+	heat           heatmap  // what happened at every cell during the latest chronons
+	overlay        int      // index of overlayNames drawn over the world
+	overlayImage   *ebiten.Image
+	overlayDrawn   int       // version of the heatmap and overlay in overlayImage
+	hud            hud       // statistics and charts of the latest chronons
+	inspect        inspector // creature under the cursor or clicked
+	trails         trailMap  // latest tiles of every creature
 }
 
 func (g *Game) AnimationSteps() int {
@@ -86,28 +129,50 @@ func (g *Game) AnimationSteps() int {
 }
 
 // Set up the initial tileMap and randomly seed it with sharks and fish.
-// If called again, it will reset the map and re-seed.
-func (g *Game) Init(numfish, numshark, width, height int) {
-
-	g.frames = nil
-	g.startFish = numfish
-	g.startShark = numshark
-	g.width = width
-	g.height = height
-	g.tpsPerChronon = 60
+// If called again, it will reset the map and re-seed.  A seed of 0 picks a
+// random one.  The game is left as it was if the parameters are refused.
+func (g *Game) Init(p wator.Params) error {
+
+	// Initialize the world.
+	world := wator.Wator{Seed: p.Seed}
+	if err := world.Init(p.Width, p.Height, p.Fish, p.Sharks, p.FishSpawnRate, p.SharkSpawnRate, p.Health); err != nil {
+		return err
+	}
+
+	conditions, err := g.newStopConditions()
+	if err != nil {
+		return err
+	}
+
+	g.stopRecording()
+	if world.Width != g.world.Width || world.Height != g.world.Height {
+		g.cam.fit = true
+	}
+	g.settings = p
+	g.world = world
+	g.inspect = inspector{}
 	g.pixelsMove = 4
-	g.tpsPerFrame = 8
-	g.ctickCounter = 0
-	g.drawFrameCounter = 0
+	g.setSpeed(g.speed)
 	g.pause = true
+	g.stopConditions = conditions
+	g.show(g.world.State())
+	g.resetHistory()
+	g.startRecording()
+	return nil
+}
 
-	if err := g.loadSprites(); err != nil {
-		log.Fatal(err)
-	}
-	// Initialize the world.
-	g.world = wator.Wator{}
-	if err := g.world.Init(width, height, numfish, numshark, *fsr, *ssr, *health); err != nil {
-		log.Fatal(err.Error())
+// settingsFromFlags returns the parameters of the first world given on the
+// command line.
+func settingsFromFlags() wator.Params {
+	return wator.Params{
+		Width:          *width,
+		Height:         *height,
+		Fish:           *startFish,
+		Sharks:         *startSharks,
+		FishSpawnRate:  *fsr,
+		SharkSpawnRate: *ssr,
+		Health:         *health,
+		Seed:           *seed,
 	}
 }
 
@@ -115,8 +180,8 @@ func (g *Game) loadSprites() error {
 
 	// Set up the sprites.
 
-	g.fishSprite = make([]*ebiten.Image, AniFrames*4+1)
-	g.sharkSprite = make([]*ebiten.Image, AniFrames*4+1)
+	g.fishSprite = make([]*ebiten.Image, AniFrames*4+DeathFrames)
+	g.sharkSprite = make([]*ebiten.Image, AniFrames*4+DeathFrames)
 
 	// Load Sprite Sheets ----------------------------
 
@@ -151,6 +216,16 @@ func (g *Game) loadSprites() error {
 		return fmt.Errorf("Unable to load dead sharks sprite sheet. %v", err)
 	}
 
+	// User interface
+	slider, _, err := ebitenutil.NewImageFromFile("assets/spearfishing/Sprites/UI/UI-Slider.png")
+	if err != nil {
+		return fmt.Errorf("Unable to load slider image. %v", err)
+	}
+	buttons, _, err := ebitenutil.NewImageFromFile("assets/spearfishing/Sprites/UI/UI-Buttons.png")
+	if err != nil {
+		return fmt.Errorf("Unable to load buttons image. %v", err)
+	}
+
 	//  Load individual images from sprite sheets.
 
 	// Regular Shark - East
@@ -164,7 +239,7 @@ func (g *Game) loadSprites() error {
 	}
 
 	// Shark eating (facing East)
-	for i, j := AltEastStartIdx, 0; i < AltEastEndIdx; i, j = i+1, j+1 {
+	for i, j := AltEastStartIdx, 0; i <= AltEastEndIdx; i, j = i+1, j+1 {
 		g.sharkSprite[i] = ss.SubImage(image.Rect(j*TileSize, 32, j*TileSize+TileSize, 64)).(*ebiten.Image)
 	}
 
@@ -174,8 +249,10 @@ func (g *Game) loadSprites() error {
 
 	}
 
-	// Shark Death
-	g.sharkSprite[DeathSpriteIdx] = sds.SubImage(image.Rect(10, 32*3, 46, 32*4)).(*ebiten.Image)
+	// Shark Death, the grey shark of the 4th row fading to bones
+	for i, j := DeathStartIdx, 0; i <= DeathEndIdx; i, j = i+1, j+1 {
+		g.sharkSprite[i] = sds.SubImage(image.Rect(j*48, 32*3, j*48+48, 32*4)).(*ebiten.Image)
+	}
 
 	// Regular Fish - East
 	for i, j := EastStartIdx, 0; i <= EastEndIdx; i, j = i+1, j+1 {
@@ -186,8 +263,26 @@ func (g *Game) loadSprites() error {
 		g.fishSprite[i+AniFrames] = fs_r.SubImage(image.Rect(i*TileSize, 0, i*TileSize+TileSize, 16)).(*ebiten.Image)
 	}
 
-	// Fish Deaith
-	g.fishSprite[DeathSpriteIdx] = fds.SubImage(image.Rect(0, 0, 32, 32)).(*ebiten.Image)
+	// Fish don't eat, so their alternative animation is the regular one.
+	for i := 0; i < AniFrames*2; i++ {
+		g.fishSprite[i+AltEastStartIdx] = g.fishSprite[i]
+	}
+
+	// Fish Death, the orange fish of the 10th row fading to bones
+	for i, j := DeathStartIdx, 0; i <= DeathEndIdx; i, j = i+1, j+1 {
+		g.fishSprite[i] = fds.SubImage(image.Rect(j*TileSize, 32*9, j*TileSize+TileSize, 32*10)).(*ebiten.Image)
+	}
+
+	// Panel, slider knob and the purple (empty) and blue (filled) tracks
+	g.panelSprite = buttons.SubImage(image.Rect(2, 4, 30, 28)).(*ebiten.Image)
+	g.knobSprite = slider.SubImage(image.Rect(54, 20, 64, 27)).(*ebiten.Image)
+	g.trackSprites = nil
+	for _, x := range [][3]int{{113, 122, 128}, {17, 26, 32}} {
+		g.trackSprites = append(g.trackSprites,
+			slider.SubImage(image.Rect(x[0], 20, x[0]+7, 27)).(*ebiten.Image),
+			slider.SubImage(image.Rect(x[1], 20, x[1]+4, 27)).(*ebiten.Image),
+			slider.SubImage(image.Rect(x[2], 20, x[2]+7, 27)).(*ebiten.Image))
+	}
 
 	return nil
 }
@@ -209,51 +304,6 @@ func (g *Game) StateToFrame(w wator.WorldState) []Frame {
 	return tiles
 }
 
-// DeltaToFrames generates intermediate tile maps to animate the movement of
-// fishes and sharks so that it doesn't look like they teleported between
-// tiles.
-func (g *Game) DeltaToFrames(delta []wator.Delta) [][]Frame {
-
-	steps := g.AnimationSteps()
-	var frames [][]Frame
-	for i := 0; i < steps; i++ {
-		offset := float64(i * g.pixelsMove)
-		frame := make([]Frame, g.world.Height*g.world.Width)
-		for _, d := range delta {
-
-			spriteIdx := i
-			x, y := g.TileCoordinate(d.From)
-			switch d.Action {
-			case wator.MOVE_EAST:
-				x += offset
-			case wator.MOVE_WEST:
-				x -= offset
-				spriteIdx += g.AnimationSteps()
-			case wator.MOVE_NORTH:
-				y -= offset
-			case wator.MOVE_SOUTH:
-				y += offset
-			case wator.DEATH:
-				spriteIdx = len(g.sharkSprite) - 1
-				//	case wator.ATE:
-				//			spriteIdx += g.AnimationSteps() * 2
-			default:
-				continue
-			}
-
-			frame[d.From] = Frame{
-				sprite:   spriteIdx,
-				tileType: d.Object,
-				x:        x,
-				y:        y,
-			}
-		}
-		frames = append(frames, frame)
-	}
-
-	return frames
-}
-
 // TileCoordinate converts the map tile index to the logical location (row, col)
 // and return the pixel location (x,y).
 func (g *Game) TileCoordinate(idx int) (float64, float64) {
@@ -264,26 +314,93 @@ func (g *Game) TileCoordinate(idx int) (float64, float64) {
 	return float64(col), float64(row)
 }
 
-// DrawFrame will paint the world and the creatures to the screen.
+// TileAt converts the pixel location (x,y) to the map tile index, the
+// reverse of TileCoordinate.  It returns false outside of the world.
+func (g *Game) TileAt(x, y int) (int, bool) {
+
+	if x < 0 || y < 0 || x >= g.world.Width*TileSize || y >= g.world.Height*TileSize {
+		return 0, false
+	}
+	return (y/TileSize)*g.world.Width + x/TileSize, true
+}
+
+// DrawFrame will paint the world and the creatures to the screen through the
+// camera.  Sprites are centered on their tile and new born creatures grow
+// and fade in.  Creatures off the screen are skipped.
 func (g *Game) DrawFrame(screen *ebiten.Image, m []Frame) {
 	opts := &ebiten.DrawImageOptions{}
 
 	for _, t := range m {
-		spriteIdx := t.sprite
-		opts.GeoM.Reset()
-		opts.GeoM.Translate(t.x, t.y)
+		var sprite *ebiten.Image
 		switch t.tileType {
 		case wator.FISH:
-			screen.DrawImage(g.fishSprite[spriteIdx], opts)
+			sprite = g.fishSprite[t.sprite]
 		case wator.SHARK:
-			screen.DrawImage(g.sharkSprite[spriteIdx], opts)
+			sprite = g.sharkSprite[t.sprite]
+		default:
+			continue
 		}
+		w, h := float64(sprite.Bounds().Dx()), float64(sprite.Bounds().Dy())
+		scale := g.cam.zoom
+		opts.ColorScale.Reset()
+		if t.born {
+			scale *= 0.25 + 0.75*t.grow
+			opts.ColorScale.ScaleAlpha(float32(t.grow))
+		}
+
+		// Sprites wider than a tile, like the dead sharks, overlap the
+		// tiles next to them.
+		pad := max(w-TileSize, 0) / 2
+		g.onScreen(t.x-pad, t.y, TileSize+2*pad, TileSize, func(sx, sy float64) {
+			opts.GeoM.Reset()
+			opts.GeoM.Translate(-w/2, -h/2)
+			opts.GeoM.Scale(scale, scale)
+			opts.GeoM.Translate(sx+(pad+TileSize/2)*g.cam.zoom, sy+TileSize/2*g.cam.zoom)
+			screen.DrawImage(sprite, opts)
+		})
 	}
 }
 
+// liveHelp is the help of a live world, one key binding per line.
+var liveHelp = []string{
+	"<SPACE> to begin/resume.",
+	"LEFT/RIGHT to step back/forward one chronon.",
+	"UP/DOWN or +/- to change the speed, T for turbo.",
+	"P for the parameter panel.",
+	"B to branch a new future.",
+	"E to edit the world.",
+	"Mouse wheel to zoom, drag or W/A/S/D to pan, F to fit.",
+	"Click a creature to inspect it, C to follow it.",
+	"V to switch between sprites and pixels, H for the overlays, L for trails.",
+	"I, G and O for the statistics, the chart and the phase plot.",
+	"R to restart, N for a new world.",
+	"Ctrl+S to save, Ctrl+L to load.",
+	"Q to quit.",
+}
+
+// playbackHelp is the help of a replay, one key binding per line.
+var playbackHelp = []string{
+	"<SPACE> to play/pause.",
+	"UP/DOWN to change the speed.",
+	"LEFT/RIGHT to step.",
+	"HOME/END or drag the bar to seek.",
+	"Mouse wheel to zoom, drag or W/A/S/D to pan, F to fit.",
+	"Click a creature to inspect it.",
+	"V to switch between sprites and pixels, H for the overlays, L for trails.",
+	"I, G and O for the statistics, the chart and the phase plot.",
+	"Q to quit.",
+}
+
 func (g *Game) ShowOptionsScreen(screen *ebiten.Image) {
 
-	msg := "<SPACE> to begin/resume.\nR to restart.\nQ to quit."
+	help := liveHelp
+	if g.player != nil {
+		help = playbackHelp
+	}
+	msg := strings.Join(help, "\n")
+	if g.ended != "" {
+		msg = "Run ended: " + g.ended + "\n\n" + msg
+	}
 	text.Draw(screen, msg, basicfont.Face7x13, 20, 50, color.Black)
 }
 
@@ -292,41 +409,125 @@ func (g *Game) ShowOptionsScreen(screen *ebiten.Image) {
 // 1/60th of a second.  TPS can be changed with the SetTPS method.
 func (g *Game) Update() error {
 
+	if g.setup != nil {
+		return g.updateSetup()
+	}
+
+	g.updateCamera(g.editor == nil, g.mouseFree())
+	if g.editor == nil {
+		g.updateInspector()
+	}
+
+	if g.player != nil {
+		return g.updatePlayback()
+	}
+
+	if g.editor != nil {
+		return g.updateEditor()
+	}
+
 	if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
-		g.pause = !g.pause
+		switch {
+		case g.ended == "":
+			g.pause = !g.pause
+		case g.resumeRun():
+			g.pause = false
+		}
 	}
 
 	if inpututil.IsKeyJustPressed(ebiten.KeyR) {
-		g.Init(g.startFish, g.startShark, g.width, g.height)
-		g.currentScreen = nil
+		if err := g.Init(g.settings); err != nil {
+			g.status = fmt.Sprintf("Unable to restart: %v", err)
+		}
+		g.ended = ""
 		return nil
 	}
 
 	if inpututil.IsKeyJustPressed(ebiten.KeyQ) {
+		g.stopRecording()
 		os.Exit(0)
 	}
 
-	if !g.pause {
-		g.ctickCounter++
-		g.drawFrameCounter++
+	if ebiten.IsKeyPressed(ebiten.KeyControl) && inpututil.IsKeyJustPressed(ebiten.KeyS) {
+		g.status = g.saveSnapshot(*snapshot)
+	}
+
+	if ebiten.IsKeyPressed(ebiten.KeyControl) && inpututil.IsKeyJustPressed(ebiten.KeyL) {
+		g.status = g.loadSnapshot(*snapshot)
+	}
+
+	if change := speedKeys(); change != 0 {
+		g.setSpeed(g.speed + change)
+	}
 
-		if g.drawFrameCounter%g.tpsPerFrame == 0 {
-			g.drawFrameCounter = 0
-			if len(g.frames) > 0 {
-				g.currentScreen = g.frames[0]
-				g.frames = g.frames[1:]
+	if inpututil.IsKeyJustPressed(ebiten.KeyT) {
+		g.toggleTurbo()
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyV) {
+		g.status = g.toggleRenderer()
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyH) {
+		g.overlay = (g.overlay + 1) % len(overlayNames)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyL) && !ebiten.IsKeyPressed(ebiten.KeyControl) {
+		g.status = g.toggleTrails()
+	}
+	g.hudKeys()
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyP) {
+		g.panelOpen = !g.panelOpen
+		g.dragging = -1
+	}
+	if g.panelOpen {
+		g.updatePanel()
+	}
+
+	if g.pause {
+		switch {
+		case inpututil.IsKeyJustPressed(ebiten.KeyLeft):
+			if changes, ok := g.history.back(); ok {
+				g.animate(changes, g.history.state())
 			}
+		case inpututil.IsKeyJustPressed(ebiten.KeyRight):
+			if g.ended != "" && !g.history.rewound() {
+				g.resumeRun()
+			}
+			changes, state, live := g.advance()
+			g.animate(changes, state)
+			if live {
+				g.checkStop()
+			}
+		case inpututil.IsKeyJustPressed(ebiten.KeyB):
+			g.status = g.branch()
+		case inpututil.IsKeyJustPressed(ebiten.KeyE):
+			g.startEditor()
+			return nil
+		case inpututil.IsKeyJustPressed(ebiten.KeyN):
+			g.setup = newSetup(g.settings, true)
+			g.panelOpen = false
+			return nil
 		}
+	}
+
+	if s := speeds[g.speed]; s.chronons > 0 && !g.pause {
+		g.runTurbo(s.chronons)
+		return nil
+	}
+
+	if !g.pause {
+		g.ctickCounter++
 
 		// Don't advance the world every update because that moves too fast
 		// for users to see the changes each Chronon.
 		if g.ctickCounter%g.tpsPerChronon == 0 {
 			g.ctickCounter = 0
 			// Advance the world 1 chronon and get the delta
-			worldStates := g.world.Update()
-			delta := worldStates.ChangeLog
-			for _, f := range g.DeltaToFrames(delta) {
-				g.frames = append(g.frames, f)
+			delta, state, live := g.advance()
+			g.animate(delta, state)
+			if live {
+				g.checkStop()
 			}
 		}
 	}
@@ -334,25 +535,282 @@ func (g *Game) Update() error {
 	return nil
 }
 
+// advance moves the game one chronon forward and returns its changes and
+// the resulting state.  A game that stepped back goes through the chronons
+// it kept before the world is updated again, live reports whether the world
+// was updated.
+func (g *Game) advance() (changes []wator.Delta, state wator.WorldState, live bool) {
+
+	if changes, ok := g.history.forward(); ok {
+		return changes, g.history.state(), false
+	}
+
+	worldStates := g.world.Update()
+	g.history.push(&g.world, worldStates.ChangeLog)
+	g.countChronon(worldStates.Stats, worldStates.Current, worldStates.ChangeLog)
+	return worldStates.ChangeLog, worldStates.Current, true
+}
+
+// newStopConditions returns the stop conditions given by the flags.
+// Conditions remember earlier chronons, so every run, branch, loaded or
+// edited world gets new ones.
+func (g *Game) newStopConditions() ([]wator.StopCondition, error) {
+
+	return wator.ParseStopConditions(*stopOn, *stopExpr)
+}
+
+// checkStop ends the run if one of the stop conditions is met by the latest
+// chronon of the world.
+func (g *Game) checkStop() {
+
+	if reason := wator.CheckStop(&g.world, g.world.Stats(), g.stopConditions...); reason != "" {
+		g.endRun(reason)
+	}
+}
+
+// resetHistory forgets the kept chronons and starts over from the current
+// chronon of the world.
+func (g *Game) resetHistory() {
+
+	g.history = newHistory(*keep, *keepMB<<20)
+	g.history.push(&g.world, nil)
+	g.resetCounts()
+}
+
+// branch continues the world from the shown chronon with a new seed so that
+// it takes a different course than before.  The chronons after the shown one
+// are forgotten.
+func (g *Game) branch() string {
+
+	if err := g.restoreShown(); err != nil {
+		return fmt.Sprintf("Unable to branch: %v", err)
+	}
+	g.world.Reseed(time.Now().UnixNano())
+	g.history.rekey(&g.world)
+	g.resetCounts()
+
+	conditions, err := g.newStopConditions()
+	if err != nil {
+		return fmt.Sprintf("Unable to branch: %v", err)
+	}
+	g.stopConditions = conditions
+	g.show(g.world.State())
+	g.ended = ""
+	g.startRecording()
+
+	return fmt.Sprintf("Branched at chronon %d with seed %d", g.world.Chronon, g.world.Seed)
+}
+
+// restoreShown continues the world from the chronon the game stepped back to
+// and forgets the chronons after it.  The panel shows and changes the rules
+// of the world, not of the shown chronon, so the world keeps its rules.
+func (g *Game) restoreShown() error {
+
+	rules := g.world.Params()
+	snap, err := g.history.snapshot()
+	if err != nil {
+		return err
+	}
+	if err := g.world.Restore(snap); err != nil {
+		return err
+	}
+	if err := g.world.SetRules(rules.FishSpawnRate, rules.SharkSpawnRate, rules.Health); err != nil {
+		return err
+	}
+	g.history.truncate()
+	return nil
+}
+
+// saveSnapshot writes the complete state of the world to the file and returns
+// a message describing the outcome.
+func (g *Game) saveSnapshot(name string) string {
+
+	// Save what is on the screen when the game stepped back.
+	snap := g.world.Snapshot()
+	if g.history.rewound() {
+		var err error
+		if snap, err = g.history.snapshot(); err != nil {
+			return fmt.Sprintf("Unable to save: %v", err)
+		}
+	}
+	var buf bytes.Buffer
+	if strings.ToLower(filepath.Ext(name)) == ".json" {
+		if err := snap.WriteJSON(&buf); err != nil {
+			return fmt.Sprintf("Unable to save: %v", err)
+		}
+	} else {
+		data, err := snap.MarshalBinary()
+		if err != nil {
+			return fmt.Sprintf("Unable to save: %v", err)
+		}
+		buf.Write(data)
+	}
+
+	if err := os.WriteFile(name, buf.Bytes(), 0644); err != nil {
+		return fmt.Sprintf("Unable to save: %v", err)
+	}
+	return fmt.Sprintf("Saved chronon %d to %s", snap.Chronon, name)
+}
+
+// loadSnapshot replaces the world with the one saved in the file and returns
+// a message describing the outcome.  The game is paused on the loaded world.
+func (g *Game) loadSnapshot(name string) string {
+
+	f, err := os.Open(name)
+	if err != nil {
+		return fmt.Sprintf("Unable to load: %v", err)
+	}
+	defer f.Close()
+
+	snap, err := wator.ReadSnapshot(f)
+	if err != nil {
+		return fmt.Sprintf("Unable to load: %v", err)
+	}
+	var world wator.Wator
+	if err := world.Restore(snap); err != nil {
+		return fmt.Sprintf("Unable to load: %v", err)
+	}
+
+	conditions, err := g.newStopConditions()
+	if err != nil {
+		return fmt.Sprintf("Unable to load: %v", err)
+	}
+
+	if world.Width != g.world.Width || world.Height != g.world.Height {
+		g.cam.fit = true
+	}
+	g.world = world
+	g.inspect = inspector{}
+	g.stopConditions = conditions
+	// Restarting gives a fresh world like the loaded one.
+	g.settings = world.Params()
+	g.settings.Seed = 0
+	g.ctickCounter = 0
+	g.show(world.State())
+	g.ended = ""
+	g.pause = true
+	g.resetHistory()
+	g.startRecording()
+
+	return fmt.Sprintf("Loaded chronon %d from %s", world.Chronon, name)
+}
+
+// endRun handles the end of a run because a stop condition was met.  The
+// game either starts a new world with a fresh seed or pauses and shows why
+// the run ended.
+func (g *Game) endRun(reason string) {
+
+	log.Printf("Run (seed %d) ended at chronon %d: %s", g.world.Seed, g.world.Chronon, reason)
+
+	if *autoRestart {
+		// A fixed seed would repeat the run so the next one is derived
+		// from it.
+		p := g.settings
+		if p.Seed != 0 {
+			p.Seed = g.world.Seed + 1
+		}
+		if err := g.Init(p); err != nil {
+			g.status = fmt.Sprintf("Unable to restart: %v", err)
+		} else {
+			g.pause = false
+			return
+		}
+	}
+
+	g.ended = reason
+	g.pause = true
+}
+
+// resumeRun carries on with a run that a stop condition ended and reports
+// whether it can.  The conditions are built again so that they only look at
+// the chronons from here on.  A condition that still holds, like an
+// extinction, ends the run again.
+func (g *Game) resumeRun() bool {
+
+	conditions, err := g.newStopConditions()
+	if err != nil {
+		g.status = fmt.Sprintf("Unable to resume: %v", err)
+		return false
+	}
+	g.stopConditions = conditions
+	g.ended = ""
+	return true
+}
+
+// chrononStatus returns the chronon on the screen and how far it is behind
+// the world after stepping back.
+func (g *Game) chrononStatus() string {
+
+	if g.history.rewound() {
+		return fmt.Sprintf("%d (-%d)", g.history.current().stats.Chronon, g.history.behind())
+	}
+	return strconv.FormatUint(uint64(g.world.Chronon), 10)
+}
+
+// shownState returns the positions of the creatures of the chronon the game
+// shows.
+func (g *Game) shownState() wator.WorldState {
+
+	if g.player != nil {
+		return g.player.State()
+	}
+	if g.history.rewound() {
+		return g.history.state()
+	}
+	return g.world.State()
+}
+
 // Draw is called by Ebiten at the refresh rate of the display to render
 // the images on the screen.  For example, when the display rate is 60Hz,
 // Ebiten will call Draw 60 times per second.  When a display has a 120Hz
 // refresh rate, Draw will be called twice as often as Update.
 func (g *Game) Draw(screen *ebiten.Image) {
 
+	if g.setup != nil {
+		g.DrawSetup(screen)
+		return
+	}
 	screen.Fill(color.RGBA{120, 180, 255, 255})
-	if g.pause {
+	if g.pause && !g.panelOpen && g.editor == nil {
 		g.ShowOptionsScreen(screen)
 	}
-	for x := 0; x < g.world.Width*TileSize; x += TileSize {
-		ebitenutil.DrawLine(screen, float64(x), 0, float64(x), float64(g.world.Height*TileSize), color.White)
+	g.followCreature()
+	g.drawGrid(screen)
+	if g.player != nil {
+		ebitenutil.DebugPrint(screen, g.playbackStatus())
+	} else if g.editor != nil {
+		ebitenutil.DebugPrint(screen, g.chrononStatus()+"  "+g.editorStatus())
+	} else {
+		ebitenutil.DebugPrint(screen, g.chrononStatus()+"  "+g.speedStatus())
 	}
-	for y := 0; y < g.world.Height*TileSize; y += TileSize {
-		ebitenutil.DrawLine(screen, 0, float64(y), float64(g.world.Width*TileSize), float64(y), color.White)
+	if g.status != "" {
+		ebitenutil.DebugPrintAt(screen, g.status, 0, 16)
 	}
-	ebitenutil.DebugPrint(screen, strconv.FormatUint(uint64(g.world.Chronon), 10))
 
-	g.DrawFrame(screen, g.currentScreen)
+	if g.trails.on && g.editor == nil && (g.history == nil || !g.history.rewound()) {
+		// The trails lead to the latest chronon.
+		g.DrawTrails(screen)
+	}
+	if g.pixelMode() {
+		g.DrawPixels(screen, g.anim.state)
+	} else {
+		g.DrawFrame(screen, g.InterpolateFrame(g.anim.changes, g.anim.state, g.anim.progress(time.Now())))
+	}
+	if g.panelOpen {
+		g.DrawPanel(screen)
+	}
+	if g.overlay != overlayNone {
+		g.DrawOverlay(screen)
+	}
+	g.DrawHUD(screen)
+	if g.editor != nil {
+		g.DrawEditor(screen)
+	} else {
+		g.DrawInspector(screen)
+	}
+	if g.player != nil {
+		g.DrawScrubber(screen)
+	}
 
 }
 
@@ -366,7 +824,16 @@ func (g *Game) Draw(screen *ebiten.Image) {
 // screen is small then the window, the images are scaled up.  If the logical
 // screen is larger, the images are scaled down.
 func (g *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
-	return TileSize * g.world.Width, TileSize * g.world.Height
+	if g.setup != nil {
+		return SetupWidth, SetupHeight
+	}
+
+	// The camera scales the world so the screen has the size of the window.
+	g.screenW, g.screenH = outsideWidth, outsideHeight
+	if g.cam.fit {
+		g.fitCamera()
+	}
+	return outsideWidth, outsideHeight
 }
 
 func main() {
@@ -377,10 +844,22 @@ func main() {
 	ebiten.SetWindowTitle("Wa-Tor")
 	ebiten.SetWindowResizable(true)
 
-	game := &Game{}
-	game.Init(*startFish, *startSharks, *width, *height)
+	game := &Game{speed: defaultSpeed, lastSpeed: defaultSpeed}
+	if err := game.loadSprites(); err != nil {
+		log.Fatal(err)
+	}
+	if *play != "" {
+		if err := game.InitReplay(*play); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		// The flags fill in the setup screen shown before the first run.
+		game.setup = newSetup(settingsFromFlags(), false)
+	}
 
-	if err := ebiten.RunGame(game); err != nil {
+	err := ebiten.RunGame(game)
+	game.stopRecording()
+	if err != nil {
 		log.Fatal(err)
 	}
 }
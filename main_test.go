@@ -1,7 +1,10 @@
 package main
 
 import (
+	"image"
 	"math"
+	"reflect"
+	"strings"
 	"testing"
 
 	"lazyhacker.dev/wa-tor/internal/wator"
@@ -69,3 +72,935 @@ func TestStateToTiles(t *testing.T) {
 		})
 	}
 }
+
+func TestScrubChronon(t *testing.T) {
+	tests := []struct {
+		x, width    int
+		first, last uint
+		want        uint
+	}{
+		{0, 101, 10, 110, 10},
+		{-5, 101, 10, 110, 10},
+		{50, 101, 10, 110, 60},
+		{100, 101, 10, 110, 110},
+		{300, 101, 10, 110, 110},
+		{25, 51, 0, 3, 2},
+		{7, 1, 4, 9, 4},
+	}
+
+	for _, tc := range tests {
+		if got := scrubChronon(tc.x, tc.width, tc.first, tc.last); got != tc.want {
+			t.Errorf("scrubChronon(%d, %d, %d, %d): expected %d, got %d", tc.x, tc.width, tc.first, tc.last, tc.want, got)
+		}
+	}
+}
+
+// historyRun runs a small world for the chronons and keeps them in the
+// history.  It returns the world and the snapshots of every chronon it went
+// through.
+func historyRun(t *testing.T, h *history, chronons int) (*wator.Wator, map[uint]wator.Snapshot) {
+
+	t.Helper()
+	w := &wator.Wator{Seed: 5}
+	if err := w.Init(10, 10, 30, 8, 3, 6, 4); err != nil {
+		t.Fatal(err)
+	}
+	snaps := map[uint]wator.Snapshot{w.Chronon: w.Snapshot()}
+	h.push(w, nil)
+	for i := 0; i < chronons; i++ {
+		h.push(w, w.Update().ChangeLog)
+		snaps[w.Chronon] = w.Snapshot()
+	}
+	return w, snaps
+}
+
+// checkShown fails the test if the shown chronon of the history isn't the
+// snapshot of the chronon.
+func checkShown(t *testing.T, h *history, snaps map[uint]wator.Snapshot, chronon uint) {
+
+	t.Helper()
+	if c := h.current().stats.Chronon; c != chronon {
+		t.Fatalf("Expected chronon %d to be shown, got %d", chronon, c)
+	}
+	if !reflect.DeepEqual(h.state(), snaps[chronon].State()) {
+		t.Errorf("Chronon %d: expected the positions of its creatures", chronon)
+	}
+	snap, err := h.snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(snap, snaps[chronon]) {
+		t.Errorf("Chronon %d: expected its complete world to be rebuilt", chronon)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	h := newHistory(3, 1<<20)
+	w, snaps := historyRun(t, h, 5)
+	if h.count != 3 || h.at(0).stats.Chronon != 3 {
+		t.Fatalf("Expected chronons 3 to 5, got %d from %d", h.count, h.at(0).stats.Chronon)
+	}
+	checkShown(t, h, snaps, 5)
+
+	for _, want := range []uint{4, 3} {
+		undone := h.current().changes
+		changes, ok := h.back()
+		if !ok {
+			t.Fatalf("Expected to step back to chronon %d", want)
+		}
+		if len(undone) > 0 && !reflect.DeepEqual(changes, wator.Invert(undone)) {
+			t.Errorf("Expected the inverse changes, got %+v", changes)
+		}
+		checkShown(t, h, snaps, want)
+	}
+	if _, ok := h.back(); ok {
+		t.Error("Expected to be unable to step back beyond the oldest chronon")
+	}
+	if !h.rewound() || h.behind() != 2 {
+		t.Errorf("Expected to be 2 chronons behind, got %d", h.behind())
+	}
+
+	if _, ok := h.forward(); !ok {
+		t.Fatal("Expected to step forward to chronon 4")
+	}
+	checkShown(t, h, snaps, 4)
+
+	// Branching from chronon 4 replaces chronon 5.
+	snap, err := h.snapshot()
+	if err == nil {
+		err = w.Restore(snap)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Reseed(99)
+	h.truncate()
+	h.rekey(w)
+	h.push(w, w.Update().ChangeLog)
+	snaps[w.Chronon] = w.Snapshot()
+	if h.count != 3 || h.rewound() {
+		t.Errorf("Expected chronons 3 to 5 after branching, got %d chronons", h.count)
+	}
+	checkShown(t, h, snaps, 5)
+	if _, ok := h.forward(); ok {
+		t.Error("Expected to be unable to step forward beyond the newest chronon")
+	}
+}
+
+func TestHistoryDepth(t *testing.T) {
+	// The depth is kept whether it holds fewer or more chronons than there
+	// are between two keyframes.
+	for _, depth := range []int{10, 100} {
+		h := newHistory(depth, 1<<30)
+		_, snaps := historyRun(t, h, 3*depth)
+		oldest := uint(2*depth + 1)
+		if h.count != depth || h.at(0).stats.Chronon != oldest || h.at(0).keyframe == nil {
+			t.Fatalf("Depth %d: expected chronons %d to %d from a keyframe, got %d from %d",
+				depth, oldest, 3*depth, h.count, h.at(0).stats.Chronon)
+		}
+		for c := uint(3*depth - 1); c >= oldest; c-- {
+			if _, ok := h.back(); !ok {
+				t.Fatalf("Depth %d: expected to step back to chronon %d", depth, c)
+			}
+			checkShown(t, h, snaps, c)
+		}
+		if _, ok := h.back(); ok {
+			t.Errorf("Depth %d: expected to be unable to step back beyond the oldest chronon", depth)
+		}
+	}
+}
+
+func TestHistoryKeyframes(t *testing.T) {
+	h := newHistory(1000, 1<<30)
+	_, snaps := historyRun(t, h, 2*keyframeInterval+10)
+	keyframes := 0
+	for i := 0; i < h.count; i++ {
+		if h.at(i).keyframe != nil {
+			keyframes++
+		}
+	}
+	if keyframes != 3 {
+		t.Errorf("Expected a keyframe every %d chronons, got %d keyframes in %d chronons", keyframeInterval, keyframes, h.count)
+	}
+
+	// Stepping back rebuilds the chronons between the keyframes.
+	for c := uint(h.count - 1); c > 0; c-- {
+		h.back()
+		if c%7 == 0 || c == keyframeInterval {
+			checkShown(t, h, snaps, c-1)
+		}
+	}
+	for c := uint(1); c < 20; c++ {
+		h.forward()
+		checkShown(t, h, snaps, c)
+	}
+}
+
+func TestHistoryMemory(t *testing.T) {
+	h := newHistory(1000, 1<<20)
+	historyRun(t, h, 0)
+	keyframe := h.size
+
+	h = newHistory(1000, 6*keyframe)
+	_, snaps := historyRun(t, h, 3*keyframeInterval)
+	total := 0
+	for i := 0; i < h.count; i++ {
+		total += h.at(i).size
+	}
+	if h.count < 2 || h.size > 6*keyframe || total != h.size {
+		t.Errorf("Expected at most %d bytes, got %d chronons using %d of %d", 6*keyframe, h.count, h.size, total)
+	}
+	if h.at(0).keyframe == nil {
+		t.Fatal("Expected the oldest chronon to be a keyframe")
+	}
+	for h.cursor > 0 {
+		h.back()
+	}
+	checkShown(t, h, snaps, h.at(0).stats.Chronon)
+
+	// The newest chronon is kept even if it is too large.
+	h = newHistory(1000, 1)
+	_, snaps = historyRun(t, h, 5)
+	if h.count != 1 || h.current().keyframe == nil {
+		t.Errorf("Expected only chronon 5 as a keyframe, got %d chronons", h.count)
+	}
+	checkShown(t, h, snaps, 5)
+}
+
+func TestBranchKeepsRules(t *testing.T) {
+	g := &Game{}
+	g.history = newHistory(100, 1<<20)
+	w, _ := historyRun(t, g.history, 5)
+	g.world = *w
+	g.history.back()
+	g.history.back()
+
+	// A rule changed while stepped back is kept by the branch.
+	if err := setRules(g, func(p *wator.Params) { p.FishSpawnRate = 7 }); err != nil {
+		t.Fatal(err)
+	}
+	g.branch()
+	if g.world.Chronon != 3 || g.history.rewound() {
+		t.Errorf("Expected to branch at chronon 3, got %d", g.world.Chronon)
+	}
+	if r := g.world.Params().FishSpawnRate; r != 7 {
+		t.Errorf("Expected the fish spawn rate of 7 to be kept, got %d", r)
+	}
+}
+
+func TestSetSpeed(t *testing.T) {
+	g := Game{pixelsMove: 4}
+	for i, s := range speeds {
+		g.tpsPerChronon = 0
+		g.setSpeed(i)
+		if s.ticks > 0 && g.tpsPerChronon != s.ticks {
+			t.Errorf("Speed %d: expected %d ticks per chronon, got %d", i, s.ticks, g.tpsPerChronon)
+		}
+	}
+
+	g.setSpeed(-3)
+	if g.speed != 0 {
+		t.Errorf("Expected the slowest speed, got %d", g.speed)
+	}
+	g.setSpeed(len(speeds) + 3)
+	if g.speed != len(speeds)-1 {
+		t.Errorf("Expected the fastest speed, got %d", g.speed)
+	}
+}
+
+func TestToggleTurbo(t *testing.T) {
+	g := Game{pixelsMove: 4, lastSpeed: defaultSpeed}
+	g.setSpeed(4)
+	g.toggleTurbo()
+	if speeds[g.speed].chronons == 0 {
+		t.Fatalf("Expected turbo mode, got speed %d", g.speed)
+	}
+	g.toggleTurbo()
+	if g.speed != 4 {
+		t.Errorf("Expected to return to speed 4, got %d", g.speed)
+	}
+}
+
+// tiles returns the type of creature drawn at every pixel position of the
+// frame.
+func tiles(frame []Frame) map[[2]float64]int {
+	m := make(map[[2]float64]int)
+	for _, f := range frame {
+		m[[2]float64{f.x, f.y}] = f.tileType
+	}
+	return m
+}
+
+func TestInterpolateFrame(t *testing.T) {
+	g := Game{pixelsMove: 4}
+	g.world.Width = 3
+	// A fish moves east from 0 to 1, leaving a newborn at 0, a shark at 4
+	// eats the fish at 5 and a shark starves at 8.
+	changes := []wator.Delta{
+		{Object: wator.FISH, From: 0, To: 1, Action: wator.MOVE_EAST},
+		{Object: wator.FISH, From: 0, To: 0, Action: wator.BIRTH},
+		{Object: wator.SHARK, From: 4, To: 5, Action: wator.ATE},
+		{Object: wator.SHARK, From: 4, To: 5, Action: wator.MOVE_EAST},
+		{Object: wator.SHARK, From: 8, To: 8, Action: wator.DEATH},
+		{Object: wator.FISH, From: 6, To: 6, Action: wator.MOVE_NONE},
+	}
+	state := wator.WorldState{
+		wator.FISH, wator.FISH, wator.NONE,
+		wator.NONE, wator.NONE, wator.SHARK,
+		wator.FISH, wator.NONE, wator.NONE,
+	}
+
+	tests := []struct {
+		progress float64
+		want     map[[2]float64]int
+	}{
+		{0.5, map[[2]float64]int{
+			{0, 0}: wator.FISH, {16, 0}: wator.FISH,
+			{64, 32}: wator.FISH, {48, 32}: wator.SHARK,
+			{0, 64}: wator.FISH, {64, 64}: wator.SHARK,
+		}},
+		{1, map[[2]float64]int{
+			{0, 0}: wator.FISH, {32, 0}: wator.FISH,
+			{64, 32}: wator.SHARK, {0, 64}: wator.FISH,
+		}},
+		{7, map[[2]float64]int{
+			{0, 0}: wator.FISH, {32, 0}: wator.FISH,
+			{64, 32}: wator.SHARK, {0, 64}: wator.FISH,
+		}},
+	}
+
+	for _, tc := range tests {
+		frame := g.InterpolateFrame(changes, state, tc.progress)
+		if got := tiles(frame); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Progress %v: expected %v, got %v", tc.progress, tc.want, got)
+		}
+	}
+
+	// The shark is drawn after the fish it eats.
+	frame := g.InterpolateFrame(changes, state, 0.5)
+	if last := frame[len(frame)-1]; last.tileType != wator.SHARK {
+		t.Errorf("Expected the shark on top, got %+v", last)
+	}
+}
+
+func TestInterpolateFrameSprites(t *testing.T) {
+	g := Game{pixelsMove: 4}
+	g.world.Width = 4
+	// A fish moves east from 0 to 1, leaving a newborn at 0, a shark at 4
+	// eats the fish at 5, a shark starves at 8 and a fish moves north from
+	// 10 to 6 where a shark from 7 eats it.
+	changes := []wator.Delta{
+		{Object: wator.FISH, From: 0, To: 1, Action: wator.MOVE_EAST},
+		{Object: wator.FISH, From: 0, To: 0, Action: wator.BIRTH},
+		{Object: wator.SHARK, From: 4, To: 5, Action: wator.ATE},
+		{Object: wator.SHARK, From: 4, To: 5, Action: wator.MOVE_EAST},
+		{Object: wator.SHARK, From: 8, To: 8, Action: wator.DEATH},
+		{Object: wator.FISH, From: 10, To: 6, Action: wator.MOVE_NORTH},
+		{Object: wator.SHARK, From: 7, To: 6, Action: wator.ATE},
+		{Object: wator.SHARK, From: 7, To: 6, Action: wator.MOVE_WEST},
+	}
+	state := wator.WorldState{
+		wator.FISH, wator.FISH, wator.NONE, wator.NONE,
+		wator.NONE, wator.SHARK, wator.SHARK, wator.NONE,
+		wator.NONE, wator.NONE, wator.NONE, wator.NONE,
+	}
+
+	find := func(frame []Frame, tileType int, x, y float64) Frame {
+		for _, f := range frame {
+			if f.tileType == tileType && f.x == x && f.y == y {
+				return f
+			}
+		}
+		t.Fatalf("Expected a frame of %d at (%v, %v) in %+v", tileType, x, y, frame)
+		return Frame{}
+	}
+
+	tests := []struct {
+		progress                  float64
+		eaten, moved, dead, shark int
+	}{
+		// The fish are whole until the sharks reach them.
+		{0.25, 0, 2, DeathStartIdx + 1, AltEastStartIdx + 2},
+		{0.75, DeathStartIdx + 2, DeathStartIdx + 2, DeathStartIdx + 3, AltEastStartIdx + 6},
+	}
+	for _, tc := range tests {
+		frame := g.InterpolateFrame(changes, state, tc.progress)
+		offset := tc.progress * TileSize
+		if f := find(frame, wator.FISH, 32, 32); f.sprite != tc.eaten {
+			t.Errorf("Progress %v: expected the eaten fish sprite %d, got %d", tc.progress, tc.eaten, f.sprite)
+		}
+		if f := find(frame, wator.FISH, 64, 64-offset); f.sprite != tc.moved {
+			t.Errorf("Progress %v: expected the fish eaten after moving sprite %d, got %d", tc.progress, tc.moved, f.sprite)
+		}
+		if f := find(frame, wator.SHARK, 0, 64); f.sprite != tc.dead {
+			t.Errorf("Progress %v: expected the starving shark sprite %d, got %d", tc.progress, tc.dead, f.sprite)
+		}
+		if f := find(frame, wator.SHARK, offset, 32); f.sprite != tc.shark {
+			t.Errorf("Progress %v: expected the eating shark sprite %d, got %d", tc.progress, tc.shark, f.sprite)
+		}
+		if f := find(frame, wator.SHARK, 96-offset, 32); f.sprite < AltWestStartIdx || f.sprite > AltWestEndIdx {
+			t.Errorf("Progress %v: expected the shark eating west, got sprite %d", tc.progress, f.sprite)
+		}
+		if f := find(frame, wator.FISH, 0, 0); !f.born || f.grow != tc.progress {
+			t.Errorf("Progress %v: expected the new born fish to appear, got %+v", tc.progress, f)
+		}
+		if f := find(frame, wator.FISH, offset, 0); f.born || f.sprite >= DeathStartIdx {
+			t.Errorf("Progress %v: expected the parent fish to swim on, got %+v", tc.progress, f)
+		}
+	}
+}
+
+func TestInterpolateFrameWrap(t *testing.T) {
+	// A 4x3 world filling a 128x96 screen.
+	g := &Game{pixelsMove: 4, screenW: 128, screenH: 96}
+	g.world.Width, g.world.Height = 4, 3
+	g.cam.zoom = 1
+
+	tests := []struct {
+		name string
+		move wator.Delta
+		want [][2]float64
+	}{
+		{"east", wator.Delta{From: 3, To: 0, Action: wator.MOVE_EAST}, [][2]float64{{-16, 0}, {112, 0}}},
+		{"west", wator.Delta{From: 4, To: 7, Action: wator.MOVE_WEST}, [][2]float64{{-16, 32}, {112, 32}}},
+		{"north", wator.Delta{From: 1, To: 9, Action: wator.MOVE_NORTH}, [][2]float64{{32, -16}, {32, 80}}},
+		{"south", wator.Delta{From: 10, To: 2, Action: wator.MOVE_SOUTH}, [][2]float64{{64, -16}, {64, 80}}},
+	}
+	for _, tc := range tests {
+		tc.move.Object = wator.SHARK
+		state := make(wator.WorldState, 12)
+		state[tc.move.To] = wator.SHARK
+
+		// Half way the shark is drawn on both sides of the edge.
+		frame := g.InterpolateFrame([]wator.Delta{tc.move}, state, 0.5)
+		if len(frame) != 1 {
+			t.Fatalf("%s: expected only the moving shark, got %+v", tc.name, frame)
+		}
+		var got [][2]float64
+		g.onScreen(frame[0].x, frame[0].y, TileSize, TileSize, func(sx, sy float64) {
+			got = append(got, [2]float64{sx, sy})
+		})
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: expected the shark drawn at %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestSliderValue(t *testing.T) {
+	track := image.Rect(10, 0, 111, 7)
+	tests := []struct {
+		x, lo, hi int
+		want      int
+	}{
+		{10, 1, 50, 1},
+		{0, 1, 50, 1},
+		{110, 1, 50, 50},
+		{500, 1, 50, 50},
+		{60, 0, 100, 50},
+		{61, 0, 11, 6},
+	}
+
+	for _, tc := range tests {
+		if got := sliderValue(tc.x, track, tc.lo, tc.hi); got != tc.want {
+			t.Errorf("sliderValue(%d, %d, %d): expected %d, got %d", tc.x, tc.lo, tc.hi, tc.want, got)
+		}
+	}
+}
+
+func TestSetupScreen(t *testing.T) {
+	p := presets[0].params
+	p.Seed = 42
+	s := newSetup(p, false)
+	if s.preset != 0 {
+		t.Errorf("Expected preset 0, got %d", s.preset)
+	}
+	if got, err := s.params(); err != nil || got != p {
+		t.Errorf("Expected %v, got %v (%v)", p, got, err)
+	}
+
+	// Changing a value leaves the preset.
+	s.selected = 3
+	s.change(5)
+	if s.preset != -1 || s.values[2] != "55" {
+		t.Errorf("Expected 55 fish and no preset, got %q and preset %d", s.values[2], s.preset)
+	}
+
+	// Typing edits the value and only keeps digits.
+	s.erase()
+	s.erase()
+	s.typeChars([]rune("5x0"))
+	if s.preset != 0 || s.values[2] != "50" {
+		t.Errorf("Expected 50 fish and preset 0, got %q and preset %d", s.values[2], s.preset)
+	}
+
+	// Cycling the presets keeps the seed.
+	s.selected = 0
+	s.change(1)
+	got, _ := s.params()
+	want := presets[1].params
+	want.Seed = 42
+	if s.preset != 1 || got != want {
+		t.Errorf("Expected preset 1 with %v, got preset %d with %v", want, s.preset, got)
+	}
+	s.change(-2)
+	if s.preset != len(presets)-1 {
+		t.Errorf("Expected preset %d, got %d", len(presets)-1, s.preset)
+	}
+
+	// An empty value is not a number.
+	s.selected = 1
+	s.values[0] = ""
+	if _, err := s.params(); err == nil || err.Error() != "Width must be a whole number." {
+		t.Errorf("Expected an error about the width, got %v", err)
+	}
+
+	// Values too large for a world are refused before it is started.
+	s.values[0] = "9999999999999999999"
+	if _, err := s.params(); err == nil || err.Error() != "Width is too large." {
+		t.Errorf("Expected the width to be too large, got %v", err)
+	}
+	s.values[0], s.values[1] = "100000", "100000"
+	if _, err := s.params(); err == nil || !strings.Contains(err.Error(), "positions") {
+		t.Errorf("Expected the world to have too many positions, got %v", err)
+	}
+}
+
+func TestTileAt(t *testing.T) {
+	g := &Game{}
+	g.world.Width, g.world.Height = 4, 3
+	tests := []struct {
+		x, y   int
+		idx    int
+		inside bool
+	}{
+		{0, 0, 0, true},
+		{31, 31, 0, true},
+		{32, 0, 1, true},
+		{100, 70, 11, true},
+		{127, 95, 11, true},
+		{128, 0, 0, false},
+		{0, 96, 0, false},
+		{-1, 5, 0, false},
+	}
+
+	for _, tc := range tests {
+		idx, inside := g.TileAt(tc.x, tc.y)
+		if idx != tc.idx || inside != tc.inside {
+			t.Errorf("TileAt(%d, %d): expected (%d, %v), got (%d, %v)", tc.x, tc.y, tc.idx, tc.inside, idx, inside)
+		}
+		if !inside {
+			continue
+		}
+		// The tile holds the point.
+		x, y := g.TileCoordinate(idx)
+		if float64(tc.x) < x || float64(tc.x) >= x+TileSize || float64(tc.y) < y || float64(tc.y) >= y+TileSize {
+			t.Errorf("TileAt(%d, %d): tile %d at (%v, %v) doesn't hold the point", tc.x, tc.y, idx, x, y)
+		}
+	}
+}
+
+func TestBrushTiles(t *testing.T) {
+	tests := []struct {
+		center, size int
+		want         []int
+	}{
+		{5, 1, []int{5}},
+		{5, 2, []int{5, 6, 9, 10}},
+		{5, 3, []int{0, 1, 2, 4, 5, 6, 8, 9, 10}},
+		// The brush wraps around the edges of the 4x3 world.
+		{0, 3, []int{11, 8, 9, 3, 0, 1, 7, 4, 5}},
+	}
+
+	for _, tc := range tests {
+		if got := brushTiles(tc.center, tc.size, 4, 3); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("brushTiles(%d, %d): expected %v, got %v", tc.center, tc.size, tc.want, got)
+		}
+	}
+}
+
+func TestRectTiles(t *testing.T) {
+	tests := []struct {
+		a, b int
+		want []int
+	}{
+		{5, 5, []int{5}},
+		{5, 10, []int{5, 6, 9, 10}},
+		{10, 5, []int{5, 6, 9, 10}},
+		{7, 4, []int{4, 5, 6, 7}},
+		{9, 2, []int{1, 2, 5, 6, 9, 10}},
+	}
+
+	for _, tc := range tests {
+		if got := rectTiles(tc.a, tc.b, 4); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("rectTiles(%d, %d): expected %v, got %v", tc.a, tc.b, tc.want, got)
+		}
+	}
+}
+
+func TestOnScreen(t *testing.T) {
+	// A 4x3 world of 128x96 pixels on a 64x64 screen.
+	g := &Game{screenW: 64, screenH: 64}
+	g.world.Width, g.world.Height = 4, 3
+	tests := []struct {
+		zoom       float64
+		camX, camY float64
+		x, y       float64
+		want       [][2]float64
+	}{
+		{1, 0, 0, 0, 0, [][2]float64{{0, 0}}},
+		// Culled to the right of the screen.
+		{1, 0, 0, 64, 0, nil},
+		// Past the east edge the west side is shown again.
+		{1, 100, 0, 0, 0, [][2]float64{{28, 0}}},
+		{1, 120, 0, 96, 0, [][2]float64{{-24, 0}}},
+		{1, 120, 80, 0, 0, [][2]float64{{8, 16}}},
+		// Zoomed out the world repeats.
+		{0.5, 16, 0, 0, 0, [][2]float64{{-8, 0}, {56, 0}, {-8, 48}, {56, 48}}},
+	}
+
+	for _, tc := range tests {
+		g.cam.zoom, g.cam.x, g.cam.y = tc.zoom, tc.camX, tc.camY
+		var got [][2]float64
+		g.onScreen(tc.x, tc.y, TileSize, TileSize, func(sx, sy float64) {
+			got = append(got, [2]float64{sx, sy})
+		})
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Camera at (%v, %v) zoomed %v, tile at (%v, %v): expected %v, got %v",
+				tc.camX, tc.camY, tc.zoom, tc.x, tc.y, tc.want, got)
+		}
+	}
+}
+
+func TestZoomAt(t *testing.T) {
+	g := &Game{screenW: 100, screenH: 100}
+	g.world.Width, g.world.Height = 10, 10
+	g.cam.zoom = 1
+	g.cam.x, g.cam.y = 10, 20
+
+	// The world under the cursor stays put.
+	wx, wy := g.ScreenToWorld(50, 40)
+	g.zoomAt(50, 40, 2)
+	if g.cam.zoom != 2 {
+		t.Errorf("Expected zoom 2, got %v", g.cam.zoom)
+	}
+	if x, y := g.ScreenToWorld(50, 40); math.Abs(x-wx) > 1e-9 || math.Abs(y-wy) > 1e-9 {
+		t.Errorf("Expected (%v, %v) under the cursor, got (%v, %v)", wx, wy, x, y)
+	}
+
+	// Zooming is limited to showing the whole world.
+	g.zoomAt(0, 0, 0.001)
+	if want := 100.0 / (10 * TileSize); g.cam.zoom != want {
+		t.Errorf("Expected zoom %v, got %v", want, g.cam.zoom)
+	}
+
+	// Panning past an edge wraps around.
+	g.cam.zoom = 1
+	g.cam.x, g.cam.y = 0, 0
+	g.pan(10, 0)
+	if g.cam.x != 10*TileSize-10 {
+		t.Errorf("Expected camera at %v, got %v", 10*TileSize-10, g.cam.x)
+	}
+	if tile, ok := g.TileAt(int(g.cam.x), 0); !ok || tile != 9 {
+		t.Errorf("Expected tile 9 at the left of the screen, got %d", tile)
+	}
+}
+
+func TestWritePixels(t *testing.T) {
+	state := wator.WorldState{wator.NONE, wator.FISH, wator.SHARK}
+	pixels := make([]byte, len(state)*4)
+	for i := range pixels {
+		pixels[i] = 7
+	}
+	writePixels(pixels, state)
+
+	want := append(append([]byte{0, 0, 0, 0}, fishPixel[:]...), sharkPixel[:]...)
+	if !reflect.DeepEqual(pixels, want) {
+		t.Errorf("Expected %v, got %v", want, pixels)
+	}
+}
+
+func TestPixelMode(t *testing.T) {
+	tests := []struct {
+		renderer      int
+		width, height int
+		zoom          float64
+		want          bool
+	}{
+		{renderAuto, 16, 12, 1, false},
+		{renderAuto, 1000, 1000, 1, true},
+		{renderAuto, 16, 12, 0.1, true},
+		{renderSprites, 1000, 1000, 0.1, false},
+		{renderPixels, 16, 12, 1, true},
+	}
+
+	for _, tc := range tests {
+		g := &Game{renderer: tc.renderer}
+		g.world.Width, g.world.Height = tc.width, tc.height
+		g.cam.zoom = tc.zoom
+		if got := g.pixelMode(); got != tc.want {
+			t.Errorf("Renderer %s for %dx%d at zoom %v: expected pixels %v, got %v",
+				renderNames[tc.renderer], tc.width, tc.height, tc.zoom, tc.want, got)
+		}
+	}
+}
+
+func TestHeatmap(t *testing.T) {
+	var h heatmap
+	h.reset(2, 3)
+	F, S := wator.FISH, wator.SHARK
+
+	h.add(wator.WorldState{F, S, 0}, nil)
+	h.add(wator.WorldState{F, 0, S}, []wator.Delta{
+		{Object: S, From: 1, To: 2, Action: wator.ATE},
+		{Object: S, From: 1, To: 2, Action: wator.MOVE_EAST},
+	})
+	// The first chronon leaves the window of 2.
+	h.add(wator.WorldState{0, F, 0}, []wator.Delta{
+		{Object: S, From: 2, To: 2, Action: wator.DEATH},
+	})
+
+	tests := []struct {
+		overlay int
+		want    []int32
+		most    int32
+	}{
+		{overlayFish, []int32{1, 1, 0}, 2},
+		{overlaySharks, []int32{0, 0, 1}, 2},
+		{overlayDeaths, []int32{0, 0, 2}, 2},
+		{overlayPredation, []int32{0, 0, 1}, 1},
+	}
+	for _, tc := range tests {
+		got, most := h.values(tc.overlay)
+		if !reflect.DeepEqual(got, tc.want) || most != tc.most {
+			t.Errorf("Overlay %s: expected %v up to %d, got %v up to %d", overlayNames[tc.overlay], tc.want, tc.most, got, most)
+		}
+	}
+
+	// A world of another size starts over.
+	h.add(wator.WorldState{F, F}, nil)
+	if got, _ := h.values(overlayFish); !reflect.DeepEqual(got, []int32{1, 1}) || h.count != 1 {
+		t.Errorf("Expected a new heatmap with 1 chronon, got %v with %d", got, h.count)
+	}
+
+	// Large worlds get a shorter window.
+	h.reset(100, heatBudget/10)
+	if h.window() != 10 {
+		t.Errorf("Expected a window of 10 chronons, got %d", h.window())
+	}
+}
+
+func TestHeatColor(t *testing.T) {
+	if c := heatColor(0); c != [4]byte{} {
+		t.Errorf("Expected the coldest colour to be transparent, got %v", c)
+	}
+	if c := heatColor(2); c != heatColor(1) || c[0] != 210 || c[3] != 210 {
+		t.Errorf("Expected the hottest colour to be red, got %v", c)
+	}
+	// The colour gets more opaque as it gets hotter.
+	for v := 0.1; v <= 1; v += 0.1 {
+		if heatColor(v)[3] <= heatColor(v - 0.1)[3] {
+			t.Errorf("Expected heatColor(%v) to be more opaque than heatColor(%v)", v, v-0.1)
+		}
+	}
+}
+
+func TestHUDSeries(t *testing.T) {
+	var h hud
+	if got := h.series(); len(got) != 0 {
+		t.Errorf("Expected no samples, got %v", got)
+	}
+	for c := uint(1); c <= hudSamples+5; c++ {
+		h.add(wator.Stats{Chronon: c})
+	}
+
+	got := h.series()
+	if len(got) != hudSamples {
+		t.Fatalf("Expected %d samples, got %d", hudSamples, len(got))
+	}
+	// The oldest samples were dropped.
+	if got[0].Chronon != 6 || got[len(got)-1].Chronon != hudSamples+5 {
+		t.Errorf("Expected chronons 6 to %d, got %d to %d", hudSamples+5, got[0].Chronon, got[len(got)-1].Chronon)
+	}
+
+	h.reset()
+	if got := h.series(); len(got) != 0 {
+		t.Errorf("Expected no samples after reset, got %d", len(got))
+	}
+}
+
+func TestStatsOf(t *testing.T) {
+	F, S := wator.FISH, wator.SHARK
+	state := wator.WorldState{F, F, S, 0}
+	changes := []wator.Delta{
+		{Object: S, From: 3, To: 2, Action: wator.ATE},
+		{Object: S, From: 3, To: 2, Action: wator.MOVE_WEST},
+		{Object: S, From: 3, To: 3, Action: wator.BIRTH},
+		{Object: S, From: 3, To: 3, Action: wator.DEATH},
+		{Object: F, From: 0, To: 1, Action: wator.MOVE_EAST},
+		{Object: F, From: 0, To: 0, Action: wator.BIRTH},
+	}
+
+	want := wator.Stats{Chronon: 7, Fish: 2, Sharks: 1, FishBorn: 1, SharksBorn: 1, FishEaten: 1, SharksStarved: 1, Occupancy: 0.75}
+	if got := statsOf(7, state, changes); got != want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
+
+func TestMoveOffset(t *testing.T) {
+	// A fish moves east onto 1 and a shark eats it there, arriving from
+	// the south, so the shark is the creature on 1.
+	changes := []wator.Delta{
+		{Object: wator.FISH, From: 0, To: 1, Action: wator.MOVE_EAST},
+		{Object: wator.SHARK, From: 4, To: 1, Action: wator.ATE},
+		{Object: wator.SHARK, From: 4, To: 1, Action: wator.MOVE_NORTH},
+		{Object: wator.FISH, From: 5, To: 5, Action: wator.MOVE_NONE},
+		{Object: wator.FISH, From: 6, To: 7, Action: wator.MOVE_WEST},
+	}
+	tests := []struct {
+		pos      int
+		progress float64
+		dx, dy   float64
+	}{
+		{1, 0.25, 0, 24},
+		{1, 1, 0, 0},
+		{5, 0.5, 0, 0},
+		{7, 0.5, 16, 0},
+		{7, 0, 32, 0},
+		{3, 0.5, 0, 0},
+	}
+	for _, tc := range tests {
+		if dx, dy := moveOffset(changes, tc.pos, tc.progress); dx != tc.dx || dy != tc.dy {
+			t.Errorf("moveOffset(%d, %v): expected (%v, %v), got (%v, %v)", tc.pos, tc.progress, tc.dx, tc.dy, dx, dy)
+		}
+	}
+}
+
+func TestCenterOn(t *testing.T) {
+	g := &Game{screenW: 100, screenH: 60}
+	g.world.Width, g.world.Height = 10, 10
+	g.cam.zoom, g.cam.fit = 2, true
+
+	g.centerOn(160, 80)
+	if g.cam.x != 135 || g.cam.y != 65 || g.cam.fit {
+		t.Errorf("Expected the camera at (135, 65), got (%v, %v)", g.cam.x, g.cam.y)
+	}
+	// Near an edge the camera wraps to the other side of the world.
+	g.centerOn(10, 10)
+	if g.cam.x != 10*TileSize-15 || g.cam.y != 10*TileSize-5 {
+		t.Errorf("Expected the camera at (%v, %v), got (%v, %v)", 10*TileSize-15, 10*TileSize-5, g.cam.x, g.cam.y)
+	}
+}
+
+func TestCreatureInfo(t *testing.T) {
+	p := wator.Params{FishSpawnRate: 3, SharkSpawnRate: 10, Health: 5}
+	tests := []struct {
+		c    wator.Creature
+		want string
+	}{
+		{wator.Creature{Species: wator.FISH}, "Fish\nReplays only have the species."},
+		{wator.Creature{Species: wator.FISH, ID: 7, Age: 2},
+			"Fish #7\nAge 2 chronons\nSpawns in 2 chronons\nNo parent, generation 0"},
+		{wator.Creature{Species: wator.SHARK, ID: 9, Age: 10, Health: 3, Parent: 4, Generation: 2},
+			"Shark #9\nAge 10 chronons\nHealth 3 of 5\nSpawns next chronon\nParent #4, generation 2"},
+	}
+	for _, tc := range tests {
+		if got := creatureInfo(tc.c, p); got != tc.want {
+			t.Errorf("Expected %q, got %q", tc.want, got)
+		}
+	}
+}
+
+func TestFate(t *testing.T) {
+	changes := []wator.Delta{
+		{Object: wator.SHARK, From: 4, To: 5, Action: wator.ATE},
+		{Object: wator.SHARK, From: 4, To: 5, Action: wator.MOVE_EAST},
+		{Object: wator.SHARK, From: 8, To: 8, Action: wator.DEATH},
+	}
+	tests := []struct {
+		pos  int
+		want string
+	}{
+		{5, "was eaten"},
+		{8, "starved"},
+		{2, "is gone"},
+	}
+	for _, tc := range tests {
+		if got := fate(changes, tc.pos); got != tc.want {
+			t.Errorf("fate(%d): expected %q, got %q", tc.pos, tc.want, got)
+		}
+	}
+}
+
+func TestTrails(t *testing.T) {
+	// A 3x3 world with a fish at 0 and sharks at 4 and 8.
+	tr := trailMap{on: true}
+	tr.reset(wator.WorldState{
+		wator.FISH, wator.NONE, wator.NONE,
+		wator.NONE, wator.SHARK, wator.NONE,
+		wator.NONE, wator.NONE, wator.SHARK,
+	}, 3)
+
+	chronons := [][]wator.Delta{
+		{
+			{Object: wator.FISH, From: 0, To: 1, Action: wator.MOVE_EAST},
+			{Object: wator.FISH, From: 0, To: 0, Action: wator.BIRTH},
+			{Object: wator.SHARK, From: 4, To: 3, Action: wator.MOVE_WEST},
+			{Object: wator.SHARK, From: 8, To: 8, Action: wator.DEATH},
+		},
+		{
+			{Object: wator.FISH, From: 1, To: 2, Action: wator.MOVE_EAST},
+			{Object: wator.SHARK, From: 3, To: 0, Action: wator.ATE},
+			{Object: wator.SHARK, From: 3, To: 0, Action: wator.MOVE_NORTH},
+		},
+		{
+			{Object: wator.FISH, From: 2, To: 5, Action: wator.MOVE_SOUTH},
+			{Object: wator.SHARK, From: 0, To: 0, Action: wator.MOVE_NONE},
+		},
+	}
+	for _, changes := range chronons {
+		tr.add(changes)
+	}
+
+	want := map[int][]int{
+		5: {1, 2, 5}, // The oldest tile was dropped.
+		0: {4, 3, 0},
+	}
+	if len(tr.trails) != len(want) {
+		t.Fatalf("Expected %d trails, got %d", len(want), len(tr.trails))
+	}
+	for pos, tiles := range want {
+		if got, ok := tr.trails[pos]; !ok || !reflect.DeepEqual(got.tiles, tiles) {
+			t.Errorf("Expected the trail %v at %d, got %+v", tiles, pos, got)
+		}
+	}
+	if tr.trails[0].species != wator.SHARK {
+		t.Errorf("Expected the shark to keep its trail, got %+v", tr.trails[0])
+	}
+
+	// Hidden trails are not kept.
+	tr.on = false
+	tr.reset(wator.WorldState{wator.FISH}, 3)
+	tr.add(chronons[0])
+	if len(tr.trails) != 0 {
+		t.Errorf("Expected no trails, got %v", tr.trails)
+	}
+}
+
+func TestTrailPath(t *testing.T) {
+	tests := []struct {
+		name  string
+		tiles []int
+		want  [][2]int
+	}{
+		// A 4x3 world.
+		{"inside", []int{0, 1, 5}, [][2]int{{0, 0}, {1, 0}, {1, 1}}},
+		{"east edge", []int{6, 7, 4}, [][2]int{{-2, 1}, {-1, 1}, {0, 1}}},
+		{"north edge", []int{1, 9}, [][2]int{{1, 3}, {1, 2}}},
+		{"both", []int{3, 0, 8}, [][2]int{{-1, 3}, {0, 3}, {0, 2}}},
+	}
+	for _, tc := range tests {
+		if got := trailPath(tc.tiles, 4, 3); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"golang.org/x/image/font/basicfont"
+	"lazyhacker.dev/wa-tor/internal/wator"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// heatBudget limits the bytes of the states the heatmap keeps, so that the
+// window is shorter for large worlds.
+const heatBudget = 32 << 20
+
+// Overlays drawn over the world.
+const (
+	overlayNone      = iota
+	overlayFish      // share of the chronons a cell held a fish
+	overlaySharks    // share of the chronons a cell held a shark
+	overlayDeaths    // fish eaten and sharks starved at a cell
+	overlayPredation // fish eaten at a cell
+)
+
+var overlayNames = []string{"none", "fish density", "shark density", "deaths", "predation"}
+
+// heatLayer is what a chronon adds to the heatmap.
+type heatLayer struct {
+	state  []byte  // Species at every cell.
+	deaths []int32 // Cells creatures died at.
+	eaten  []int32 // Cells fish were eaten at.
+}
+
+// heatmap counts what happened at every cell over a sliding window of the
+// latest chronons.
+type heatmap struct {
+	layers  []heatLayer // Ring buffer of the chronons in the window.
+	next    int         // Index of layers the next chronon goes to.
+	count   int         // Number of chronons in the window.
+	fish    []int32     // Chronons of the window each cell held a fish.
+	sharks  []int32     // Chronons of the window each cell held a shark.
+	deaths  []int32     // Deaths at each cell during the window.
+	eaten   []int32     // Fish eaten at each cell during the window.
+	version int         // Changes every time the counts change.
+}
+
+// reset forgets the chronons counted and sizes the heatmap for a world of
+// cells cells and a window of at most window chronons.
+func (h *heatmap) reset(window, cells int) {
+
+	window = max(min(window, heatBudget/max(cells, 1)), 1)
+	*h = heatmap{
+		layers:  make([]heatLayer, window),
+		fish:    make([]int32, cells),
+		sharks:  make([]int32, cells),
+		deaths:  make([]int32, cells),
+		eaten:   make([]int32, cells),
+		version: h.version + 1,
+	}
+}
+
+// window returns the number of chronons the heatmap counts at most.
+func (h *heatmap) window() int {
+	return len(h.layers)
+}
+
+// add counts the chronon with the state and the changes leading to it.  The
+// oldest chronon leaves the window when it is full.
+func (h *heatmap) add(state wator.WorldState, changes []wator.Delta) {
+
+	if len(state) != len(h.fish) || len(h.layers) == 0 {
+		h.reset(*heatWindow, len(state))
+	}
+
+	l := &h.layers[h.next]
+	if h.count == len(h.layers) {
+		h.apply(l, -1)
+	} else {
+		h.count++
+	}
+
+	l.state = l.state[:0]
+	for _, t := range state {
+		l.state = append(l.state, byte(t))
+	}
+	l.deaths, l.eaten = l.deaths[:0], l.eaten[:0]
+	for _, d := range changes {
+		switch d.Action {
+		case wator.DEATH:
+			l.deaths = append(l.deaths, int32(d.From))
+		case wator.ATE:
+			l.deaths = append(l.deaths, int32(d.To))
+			l.eaten = append(l.eaten, int32(d.To))
+		}
+	}
+
+	h.apply(l, 1)
+	h.next = (h.next + 1) % len(h.layers)
+	h.version++
+}
+
+// apply adds the layer to the counts, or takes it off for a sign of -1.
+func (h *heatmap) apply(l *heatLayer, sign int32) {
+
+	for i, t := range l.state {
+		switch int(t) {
+		case wator.FISH:
+			h.fish[i] += sign
+		case wator.SHARK:
+			h.sharks[i] += sign
+		}
+	}
+	for _, p := range l.deaths {
+		h.deaths[p] += sign
+	}
+	for _, p := range l.eaten {
+		h.eaten[p] += sign
+	}
+}
+
+// values returns the counts of the overlay and the count shown as the
+// hottest colour.  Densities are relative to the chronons in the window and
+// events to the cell with the most of them.
+func (h *heatmap) values(overlay int) ([]int32, int32) {
+
+	var v []int32
+	switch overlay {
+	case overlayFish:
+		return h.fish, int32(max(h.count, 1))
+	case overlaySharks:
+		return h.sharks, int32(max(h.count, 1))
+	case overlayDeaths:
+		v = h.deaths
+	case overlayPredation:
+		v = h.eaten
+	}
+	var most int32 = 1
+	for _, n := range v {
+		most = max(most, n)
+	}
+	return v, most
+}
+
+// heatStops are the colours of the heat scale from cold to hot.
+var heatStops = [][4]float64{
+	{0, 0, 255, 0},
+	{0, 60, 255, 110},
+	{0, 220, 120, 150},
+	{255, 230, 0, 180},
+	{255, 30, 0, 210},
+}
+
+// heatColor returns the colour of the value between 0 and 1 on the heat
+// scale, as premultiplied RGBA.
+func heatColor(v float64) [4]byte {
+
+	v = min(max(v, 0), 1) * float64(len(heatStops)-1)
+	i := min(int(v), len(heatStops)-2)
+	f := v - float64(i)
+	a, b := heatStops[i], heatStops[i+1]
+
+	alpha := a[3] + (b[3]-a[3])*f
+	var c [4]byte
+	for k := 0; k < 3; k++ {
+		c[k] = byte((a[k] + (b[k]-a[k])*f) * alpha / 255)
+	}
+	c[3] = byte(alpha)
+	return c
+}
+
+// DrawOverlay draws the heat of the cells for the selected overlay through
+// the camera and its legend.  The image is only written again when the
+// counts changed.
+func (g *Game) DrawOverlay(screen *ebiten.Image) {
+
+	w, h := g.world.Width, g.world.Height
+	values, most := g.heat.values(g.overlay)
+	if w*h == 0 || len(values) != w*h {
+		return
+	}
+	if g.overlayImage == nil || g.overlayImage.Bounds().Dx() != w || g.overlayImage.Bounds().Dy() != h {
+		if g.overlayImage != nil {
+			g.overlayImage.Deallocate()
+		}
+		g.overlayImage = ebiten.NewImage(w, h)
+		g.overlayDrawn = -1
+	}
+	if version := g.heat.version*len(overlayNames) + g.overlay; version != g.overlayDrawn {
+		pixels := make([]byte, w*h*4)
+		for i, n := range values {
+			if n > 0 {
+				c := heatColor(float64(n) / float64(most))
+				copy(pixels[i*4:], c[:])
+			}
+		}
+		g.overlayImage.WritePixels(pixels)
+		g.overlayDrawn = version
+	}
+
+	scale := TileSize * g.cam.zoom
+	opts := &ebiten.DrawImageOptions{}
+	ww, wh := g.worldSize()
+	g.onScreen(0, 0, ww, wh, func(sx, sy float64) {
+		opts.GeoM.Reset()
+		opts.GeoM.Scale(scale, scale)
+		opts.GeoM.Translate(sx, sy)
+		screen.DrawImage(g.overlayImage, opts)
+	})
+
+	g.drawLegend(screen, most)
+}
+
+// drawLegend draws the heat scale of the overlay in the bottom right
+// corner.
+func (g *Game) drawLegend(screen *ebiten.Image, most int32) {
+
+	const barW, barH, steps = 160, 10, 32
+	x := float32(g.screenW - barW - 16)
+	y := float32(g.screenH - barH - 24)
+	if g.player != nil {
+		y -= ScrubberHeight
+	}
+	vector.DrawFilledRect(screen, x-6, y-20, barW+12, barH+40, color.RGBA{0, 0, 0, 140}, false)
+	for i := 0; i < steps; i++ {
+		c := heatColor(float64(i+1) / steps)
+		clr := color.RGBA{c[0], c[1], c[2], c[3]}
+		vector.DrawFilledRect(screen, x+float32(i)*barW/steps, y, barW/steps+1, barH, clr, false)
+	}
+
+	low, high := "0", fmt.Sprint(most)
+	if g.overlay == overlayFish || g.overlay == overlaySharks {
+		low, high = "0%", "100%"
+	}
+	title := fmt.Sprintf("%s, %d chronons", overlayNames[g.overlay], g.heat.count)
+	text.Draw(screen, title, basicfont.Face7x13, int(x), int(y)-6, color.White)
+	text.Draw(screen, low, basicfont.Face7x13, int(x), int(y)+barH+14, color.White)
+	text.Draw(screen, high, basicfont.Face7x13, int(x)+barW-7*len(high), int(y)+barH+14, color.White)
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+
+	"golang.org/x/image/font/basicfont"
+	"lazyhacker.dev/wa-tor/internal/wator"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+)
+
+const (
+	PanelX      = 8   // left of the parameter panel
+	PanelY      = 36  // top of the parameter panel
+	PanelWidth  = 290 // width of the parameter panel
+	PanelRowH   = 30  // height of a slider in the panel
+	PanelBorder = 6   // pixels of the panel sprite that are not stretched
+	TrackHeight = 7   // height of the slider sprites
+)
+
+// slider is a control of the panel picking a whole number in a range.  It
+// shows the value of the game so that a change the game refused snaps back.
+type slider struct {
+	label    string
+	min, max int
+	get      func(g *Game) int
+	set      func(g *Game, v int) error
+	text     func(g *Game) string // describes the value, the number if nil
+}
+
+// setRules changes one of the rules of the running world and keeps the
+// others.
+func setRules(g *Game, change func(p *wator.Params)) error {
+
+	p := g.world.Params()
+	change(&p)
+	if err := g.world.SetRules(p.FishSpawnRate, p.SharkSpawnRate, p.Health); err != nil {
+		return err
+	}
+	// The chronons after this one can't be rebuilt with the old rules.
+	g.history.rekey(&g.world)
+	return nil
+}
+
+// sliders are the controls of the parameter panel from top to bottom.
+var sliders = []slider{
+	{
+		label: "Fish spawn rate", min: 1, max: 50,
+		get: func(g *Game) int { return g.world.Params().FishSpawnRate },
+		set: func(g *Game, v int) error {
+			return setRules(g, func(p *wator.Params) { p.FishSpawnRate = v })
+		},
+	},
+	{
+		label: "Shark spawn rate", min: 1, max: 100,
+		get: func(g *Game) int { return g.world.Params().SharkSpawnRate },
+		set: func(g *Game, v int) error {
+			return setRules(g, func(p *wator.Params) { p.SharkSpawnRate = v })
+		},
+	},
+	{
+		label: "Shark health", min: 1, max: 100,
+		get: func(g *Game) int { return g.world.Params().Health },
+		set: func(g *Game, v int) error {
+			return setRules(g, func(p *wator.Params) { p.Health = v })
+		},
+	},
+	{
+		label: "Speed", min: 0, max: len(speeds) - 1,
+		get:  func(g *Game) int { return g.speed },
+		set:  func(g *Game, v int) error { g.setSpeed(v); return nil },
+		text: func(g *Game) string { return g.speedStatus() },
+	},
+}
+
+// trackRect returns the area of the track of the slider in the panel.
+func trackRect(i int) image.Rectangle {
+
+	x := PanelX + 2*PanelBorder
+	y := PanelY + PanelBorder + i*PanelRowH + 16
+	return image.Rect(x, y, PanelX+PanelWidth-2*PanelBorder, y+TrackHeight)
+}
+
+// panelRect returns the area of the parameter panel.
+func panelRect() image.Rectangle {
+	return image.Rect(PanelX, PanelY, PanelX+PanelWidth, PanelY+2*PanelBorder+len(sliders)*PanelRowH)
+}
+
+// sliderValue returns the value of a slider spanning lo to hi when the track
+// is clicked at x.
+func sliderValue(x int, track image.Rectangle, lo, hi int) int {
+
+	if track.Dx() <= 1 {
+		return lo
+	}
+	f := float64(x-track.Min.X) / float64(track.Dx()-1)
+	v := lo + int(math.Round(f*float64(hi-lo)))
+	return min(max(v, lo), hi)
+}
+
+// updatePanel moves the slider being dragged and reports whether the panel
+// took the mouse so that nothing under it reacts to it.
+func (g *Game) updatePanel() bool {
+
+	x, y := ebiten.CursorPosition()
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		g.dragging = -1
+		for i := range sliders {
+			// Be forgiving about clicks just next to the thin track.
+			if image.Pt(x, y).In(trackRect(i).Inset(-4)) {
+				g.dragging = i
+			}
+		}
+	}
+	if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+		g.dragging = -1
+	}
+
+	if g.dragging >= 0 {
+		s := sliders[g.dragging]
+		v := sliderValue(x, trackRect(g.dragging), s.min, s.max)
+		if v != s.get(g) {
+			g.status = ""
+			if err := s.set(g, v); err != nil {
+				g.status = err.Error()
+			}
+		}
+		return true
+	}
+
+	return image.Pt(x, y).In(panelRect()) && ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft)
+}
+
+// DrawPanel draws the parameter panel with the sprites of the user interface.
+func (g *Game) DrawPanel(screen *ebiten.Image) {
+
+	drawNineSlice(screen, g.panelSprite, panelRect(), PanelBorder)
+
+	for i, s := range sliders {
+		value := strconv.Itoa(s.get(g))
+		if s.text != nil {
+			value = s.text(g)
+		}
+		track := trackRect(i)
+		text.Draw(screen, fmt.Sprintf("%s: %s", s.label, value), basicfont.Face7x13, track.Min.X, track.Min.Y-4, color.White)
+
+		// The part of the track up to the knob is filled.
+		knob := track.Min.X
+		if s.max > s.min {
+			knob += (s.get(g) - s.min) * (track.Dx() - 1) / (s.max - s.min)
+		}
+		drawTrack(screen, g.trackSprites[0:3], track)
+		drawTrack(screen, g.trackSprites[3:6], image.Rect(track.Min.X, track.Min.Y, max(knob, track.Min.X+8), track.Max.Y))
+
+		knobWidth := g.knobSprite.Bounds().Dx()
+		opts := &ebiten.DrawImageOptions{}
+		opts.GeoM.Translate(float64(knob-knobWidth/2), float64(track.Min.Y))
+		screen.DrawImage(g.knobSprite, opts)
+	}
+}
+
+// drawTrack draws a track from its left end, middle and right end sprites
+// with the middle stretched to fill the area.
+func drawTrack(dst *ebiten.Image, parts []*ebiten.Image, r image.Rectangle) {
+
+	left, mid, right := parts[0].Bounds().Dx(), parts[1].Bounds().Dx(), parts[2].Bounds().Dx()
+	opts := &ebiten.DrawImageOptions{}
+
+	opts.GeoM.Translate(float64(r.Min.X), float64(r.Min.Y))
+	dst.DrawImage(parts[0], opts)
+
+	if stretch := r.Dx() - left - right; stretch > 0 {
+		opts.GeoM.Reset()
+		opts.GeoM.Scale(float64(stretch)/float64(mid), 1)
+		opts.GeoM.Translate(float64(r.Min.X+left), float64(r.Min.Y))
+		dst.DrawImage(parts[1], opts)
+	}
+
+	opts.GeoM.Reset()
+	opts.GeoM.Translate(float64(r.Max.X-right), float64(r.Min.Y))
+	dst.DrawImage(parts[2], opts)
+}
+
+// drawNineSlice draws the sprite stretched over the area without stretching
+// the corners so that its border keeps its thickness.
+func drawNineSlice(dst, src *ebiten.Image, r image.Rectangle, border int) {
+
+	sb := src.Bounds()
+	// Columns and rows of the source and destination split at the border.
+	sx := []int{sb.Min.X, sb.Min.X + border, sb.Max.X - border, sb.Max.X}
+	sy := []int{sb.Min.Y, sb.Min.Y + border, sb.Max.Y - border, sb.Max.Y}
+	dx := []int{r.Min.X, r.Min.X + border, r.Max.X - border, r.Max.X}
+	dy := []int{r.Min.Y, r.Min.Y + border, r.Max.Y - border, r.Max.Y}
+
+	opts := &ebiten.DrawImageOptions{}
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			part := src.SubImage(image.Rect(sx[col], sy[row], sx[col+1], sy[row+1])).(*ebiten.Image)
+			opts.GeoM.Reset()
+			opts.GeoM.Scale(float64(dx[col+1]-dx[col])/float64(sx[col+1]-sx[col]),
+				float64(dy[row+1]-dy[row])/float64(sy[row+1]-sy[row]))
+			opts.GeoM.Translate(float64(dx[col]), float64(dy[row]))
+			dst.DrawImage(part, opts)
+		}
+	}
+}
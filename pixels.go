@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+
+	"lazyhacker.dev/wa-tor/internal/wator"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	pixelCells     = 40000 // worlds with more cells are drawn as pixels by default
+	minSpritePitch = 6     // tiles smaller than this on the screen are drawn as pixels by default
+)
+
+// Renderers of the world.
+const (
+	renderAuto    = iota // pixels for large worlds or when zoomed far out
+	renderSprites        // an animated sprite for every creature
+	renderPixels         // a pixel of colour for every cell
+)
+
+var renderNames = []string{"auto", "sprites", "pixels"}
+
+// Colours of the cells when drawn as pixels.  Water is left transparent so
+// that the ocean shows through.
+var (
+	fishPixel  = [4]byte{255, 150, 40, 255}
+	sharkPixel = [4]byte{40, 40, 70, 255}
+)
+
+// pixelMap is the world drawn with a pixel per cell into a single image.
+type pixelMap struct {
+	image  *ebiten.Image
+	pixels []byte
+	state  wator.WorldState // State the image was last written from.
+}
+
+// pixelMode reports whether the world is drawn as pixels instead of sprites.
+func (g *Game) pixelMode() bool {
+
+	switch g.renderer {
+	case renderSprites:
+		return false
+	case renderPixels:
+		return true
+	}
+	return g.world.Width*g.world.Height > pixelCells || TileSize*g.cam.zoom < minSpritePitch
+}
+
+// toggleRenderer switches to the next renderer and returns a message saying
+// which one is used.
+func (g *Game) toggleRenderer() string {
+
+	g.renderer = (g.renderer + 1) % len(renderNames)
+	using := "sprites"
+	if g.pixelMode() {
+		using = "pixels"
+	}
+	return fmt.Sprintf("Renderer: %s (%s)", renderNames[g.renderer], using)
+}
+
+// writePixels sets the colours of the pixels of the cells from the state.
+func writePixels(pixels []byte, state wator.WorldState) {
+
+	for i, t := range state {
+		var c [4]byte
+		switch t {
+		case wator.FISH:
+			c = fishPixel
+		case wator.SHARK:
+			c = sharkPixel
+		}
+		copy(pixels[i*4:], c[:])
+	}
+}
+
+// DrawPixels draws the state with a pixel per cell through the camera.  The
+// image is only written again when the state changed.
+func (g *Game) DrawPixels(screen *ebiten.Image, state wator.WorldState) {
+
+	w, h := g.world.Width, g.world.Height
+	if w*h == 0 || len(state) != w*h {
+		return
+	}
+	pm := &g.pixels
+	if pm.image == nil || pm.image.Bounds().Dx() != w || pm.image.Bounds().Dy() != h {
+		if pm.image != nil {
+			pm.image.Deallocate()
+		}
+		pm.image = ebiten.NewImage(w, h)
+		pm.pixels = make([]byte, w*h*4)
+		pm.state = nil
+	}
+	if len(pm.state) != len(state) || &pm.state[0] != &state[0] {
+		writePixels(pm.pixels, state)
+		pm.image.WritePixels(pm.pixels)
+		pm.state = state
+	}
+
+	scale := TileSize * g.cam.zoom
+	opts := &ebiten.DrawImageOptions{}
+	if scale < 1 {
+		// Cells smaller than a pixel of the screen blend into a density.
+		opts.Filter = ebiten.FilterLinear
+	}
+	ww, wh := g.worldSize()
+	g.onScreen(0, 0, ww, wh, func(sx, sy float64) {
+		opts.GeoM.Reset()
+		opts.GeoM.Scale(scale, scale)
+		opts.GeoM.Translate(sx, sy)
+		screen.DrawImage(pm.image, opts)
+	})
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"os"
+
+	"lazyhacker.dev/wa-tor/internal/wator"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	ScrubberHeight = 12 // pixels at the bottom of the screen used by the scrubber
+)
+
+// playSpeeds are the number of ticks per chronon during playback, from the
+// slowest to the fastest speed.
+var playSpeeds = []int{128, 64, 32, 16, 8}
+
+// normalSpeed is the index of playSpeeds matching the speed of a live game.
+const normalSpeed = 1
+
+// startRecording writes the replay of the current world to the file given
+// with -record.  A file that was being written is closed first so every new
+// world starts the replay over.
+func (g *Game) startRecording() {
+
+	g.stopRecording()
+	if *record == "" {
+		return
+	}
+
+	rw, err := wator.CreateReplay(&g.world, *record)
+	if err != nil {
+		g.status = fmt.Sprintf("Unable to record: %v", err)
+		log.Print(g.status)
+		return
+	}
+	g.recording = rw
+}
+
+// stopRecording finishes the replay being written, if any.
+func (g *Game) stopRecording() {
+
+	if g.recording == nil {
+		return
+	}
+	if err := g.recording.Stop(); err != nil {
+		log.Printf("Unable to write replay %s: %v", *record, err)
+	}
+	g.recording = nil
+}
+
+// InitReplay sets up the game to play back the replay in the file instead of
+// running a live world.
+func (g *Game) InitReplay(name string) error {
+
+	f, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("Unable to open replay. %v", err)
+	}
+	defer f.Close()
+	replay, err := wator.ReadReplay(f)
+	if err != nil {
+		return fmt.Errorf("Unable to read replay %s. %v", name, err)
+	}
+
+	// The world only provides the dimensions of the screen.
+	g.world = wator.Wator{}
+	if err := g.world.Restore(replay.Start); err != nil {
+		return err
+	}
+	g.player = wator.NewPlayer(replay)
+	g.cam.fit = true
+	g.pixelsMove = 4
+	g.setPlaySpeed(normalSpeed)
+	g.seek(g.player.First())
+	g.pause = true
+
+	return nil
+}
+
+// setPlaySpeed changes the playback speed to the index of playSpeeds.
+func (g *Game) setPlaySpeed(speed int) {
+
+	g.playSpeed = min(max(speed, 0), len(playSpeeds)-1)
+	g.tpsPerChronon = playSpeeds[g.playSpeed]
+}
+
+// seek shows the chronon of the replay without animating it.
+func (g *Game) seek(chronon uint) {
+
+	// The overlays count the chronons played in a row.
+	if chronon == g.player.Chronon()+1 {
+		if changes, ok := g.player.Next(); ok {
+			state := g.player.State()
+			g.countChronon(statsOf(g.player.Chronon(), state, changes), state, changes)
+		}
+	} else {
+		g.player.Seek(chronon)
+		g.resetCounts()
+	}
+	g.ctickCounter = 0
+	g.show(g.player.State())
+}
+
+// updatePlayback handles the controls of the playback and animates the
+// replay the same way as a live world.
+func (g *Game) updatePlayback() error {
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyQ) {
+		os.Exit(0)
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+		if g.player.Chronon() == g.player.Last() {
+			g.seek(g.player.First())
+		}
+		g.pause = !g.pause
+	}
+
+	if change := speedKeys(); change != 0 {
+		g.setPlaySpeed(g.playSpeed + change)
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyV) {
+		g.status = g.toggleRenderer()
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyH) {
+		g.overlay = (g.overlay + 1) % len(overlayNames)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyL) {
+		g.status = g.toggleTrails()
+	}
+	g.hudKeys()
+
+	// Stepping through the chronons pauses the playback.
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyRight):
+		g.pause = true
+		g.seek(g.player.Chronon() + 1)
+	case inpututil.IsKeyJustPressed(ebiten.KeyLeft) && g.player.Chronon() > g.player.First():
+		g.pause = true
+		g.seek(g.player.Chronon() - 1)
+	case inpututil.IsKeyJustPressed(ebiten.KeyHome):
+		g.seek(g.player.First())
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnd):
+		g.seek(g.player.Last())
+	}
+
+	// Dragging keeps seeking even when the cursor leaves the scrubber.
+	x, y := ebiten.CursorPosition()
+	screenWidth, screenHeight := g.screenW, g.screenH
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) && y >= screenHeight-ScrubberHeight {
+		g.scrubbing = true
+	}
+	if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+		g.scrubbing = false
+	}
+	if g.scrubbing {
+		if c := scrubChronon(x, screenWidth, g.player.First(), g.player.Last()); c != g.player.Chronon() {
+			g.seek(c)
+		}
+		return nil
+	}
+
+	if g.pause {
+		return nil
+	}
+
+	g.ctickCounter++
+	if g.ctickCounter%g.tpsPerChronon == 0 {
+		g.ctickCounter = 0
+		delta, ok := g.player.Next()
+		if !ok {
+			g.pause = true
+			return nil
+		}
+		state := g.player.State()
+		g.countChronon(statsOf(g.player.Chronon(), state, delta), state, delta)
+		g.animate(delta, state)
+	}
+
+	return nil
+}
+
+// scrubChronon returns the chronon at the horizontal position x of a
+// scrubber that is width pixels wide and spans the chronons first to last.
+func scrubChronon(x, width int, first, last uint) uint {
+
+	if width <= 1 || x <= 0 {
+		return first
+	}
+	if x >= width-1 {
+		return last
+	}
+	return first + uint(float64(x)/float64(width-1)*float64(last-first)+0.5)
+}
+
+// DrawScrubber draws the progress of the playback at the bottom of the screen.
+func (g *Game) DrawScrubber(screen *ebiten.Image) {
+
+	w, h := g.screenW, g.screenH
+	top := float32(h - ScrubberHeight)
+	vector.DrawFilledRect(screen, 0, top, float32(w), ScrubberHeight, color.RGBA{40, 40, 60, 160}, false)
+
+	first, last := g.player.First(), g.player.Last()
+	if last > first {
+		done := float32(g.player.Chronon()-first) / float32(last-first)
+		vector.DrawFilledRect(screen, 0, top+2, done*float32(w), ScrubberHeight-4, color.RGBA{255, 200, 0, 255}, false)
+	}
+}
+
+// playbackStatus describes the position and speed of the playback.
+func (g *Game) playbackStatus() string {
+
+	speed := float64(playSpeeds[normalSpeed]) / float64(g.tpsPerChronon)
+	return fmt.Sprintf("%d / %d  x%g", g.player.Chronon(), g.player.Last(), speed)
+}
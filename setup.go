@@ -0,0 +1,321 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"strconv"
+
+	"golang.org/x/image/font/basicfont"
+	"lazyhacker.dev/wa-tor/internal/wator"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+)
+
+const (
+	SetupWidth  = 512 // logical width of the setup screen
+	SetupHeight = 384 // logical height of the setup screen
+	SetupRowH   = 20  // height of a row of the setup screen
+)
+
+// preset is a named set of parameters to start a world with.
+type preset struct {
+	name   string
+	params wator.Params
+}
+
+// presets are the worlds the setup screen offers.  The seed is left to the
+// user.
+var presets = []preset{
+	{"Default", wator.Params{Width: 16, Height: 12, Fish: 50, Sharks: 10, FishSpawnRate: 15, SharkSpawnRate: 50, Health: 20}},
+	{"Dewdney", wator.Params{Width: 80, Height: 23, Fish: 200, Sharks: 20, FishSpawnRate: 3, SharkSpawnRate: 10, Health: 3}},
+	{"Large ocean", wator.Params{Width: 64, Height: 48, Fish: 600, Sharks: 60, FishSpawnRate: 15, SharkSpawnRate: 50, Health: 20}},
+	{"Shark frenzy", wator.Params{Width: 32, Height: 24, Fish: 200, Sharks: 100, FishSpawnRate: 5, SharkSpawnRate: 20, Health: 8}},
+	{"Million cells", wator.Params{Width: 1000, Height: 1000, Fish: 200000, Sharks: 20000, FishSpawnRate: 3, SharkSpawnRate: 10, Health: 3}},
+}
+
+// setupFields are the values of the setup screen below the preset.  field
+// returns a pointer to the int or int64 holding the value.
+var setupFields = []struct {
+	label string
+	field func(p *wator.Params) any
+}{
+	{"Width", func(p *wator.Params) any { return &p.Width }},
+	{"Height", func(p *wator.Params) any { return &p.Height }},
+	{"Fish", func(p *wator.Params) any { return &p.Fish }},
+	{"Sharks", func(p *wator.Params) any { return &p.Sharks }},
+	{"Fish spawn rate", func(p *wator.Params) any { return &p.FishSpawnRate }},
+	{"Shark spawn rate", func(p *wator.Params) any { return &p.SharkSpawnRate }},
+	{"Shark health", func(p *wator.Params) any { return &p.Health }},
+	{"Seed (0 for random)", func(p *wator.Params) any { return &p.Seed }},
+}
+
+// setupScreen is the screen picking the parameters of a new world.  The
+// values are kept as typed so that a number can be edited digit by digit.
+type setupScreen struct {
+	values   []string // text of setupFields
+	selected int      // row being edited, 0 is the preset
+	preset   int      // index of presets or -1 if the values match none
+	err      string   // why the world could not be started
+	back     bool     // there is a world to go back to
+}
+
+// newSetup returns the setup screen starting from the parameters.  back is
+// whether there is a world to go back to without starting a new one.
+func newSetup(p wator.Params, back bool) *setupScreen {
+
+	s := &setupScreen{back: back}
+	s.setParams(p)
+	return s
+}
+
+// setParams shows the parameters on the screen.
+func (s *setupScreen) setParams(p wator.Params) {
+
+	s.values = make([]string, len(setupFields))
+	for i, f := range setupFields {
+		s.values[i] = strconv.FormatInt(getField(f.field(&p)), 10)
+	}
+	s.preset = matchPreset(p)
+}
+
+// params returns the parameters on the screen.  It fails if a value is not
+// a number, is too large or the parameters don't make a world Init accepts,
+// so that the screen can say why before a world is started.
+func (s *setupScreen) params() (wator.Params, error) {
+
+	var p wator.Params
+	for i, f := range setupFields {
+		n, err := strconv.ParseInt(s.values[i], 10, 64)
+		switch {
+		case errors.Is(err, strconv.ErrRange):
+			return p, fmt.Errorf("%s is too large.", f.label)
+		case err != nil:
+			return p, fmt.Errorf("%s must be a whole number.", f.label)
+		case !setField(f.field(&p), n):
+			return p, fmt.Errorf("%s is too large.", f.label)
+		}
+	}
+	return p, p.Validate()
+}
+
+// matchPreset returns the index of the preset with the parameters, ignoring
+// the seed, or -1 if there is none.
+func matchPreset(p wator.Params) int {
+
+	p.Seed = 0
+	for i, pr := range presets {
+		if pr.params == p {
+			return i
+		}
+	}
+	return -1
+}
+
+// change steps the selected row by delta.  The preset row cycles through the
+// presets and keeps the seed, which is the last field.
+func (s *setupScreen) change(delta int) {
+
+	s.err = ""
+	if s.selected == 0 {
+		next := (s.preset + delta + len(presets)) % len(presets)
+		if s.preset < 0 && delta < 0 {
+			next = len(presets) - 1
+		}
+		seed := s.values[len(s.values)-1]
+		s.setParams(presets[next].params)
+		s.values[len(s.values)-1] = seed
+		return
+	}
+
+	i := s.selected - 1
+	n, err := strconv.ParseInt(s.values[i], 10, 64)
+	if err != nil {
+		n = 0
+	}
+	s.values[i] = strconv.FormatInt(n+int64(delta), 10)
+	s.updatePreset()
+}
+
+// typeChars adds the digits typed to the selected value.  A minus sign is
+// only taken at the start.
+func (s *setupScreen) typeChars(chars []rune) {
+
+	if s.selected == 0 {
+		return
+	}
+	i := s.selected - 1
+	for _, c := range chars {
+		if (c >= '0' && c <= '9') || (c == '-' && s.values[i] == "") {
+			if len(s.values[i]) < 19 {
+				s.values[i] += string(c)
+				s.err = ""
+			}
+		}
+	}
+	s.updatePreset()
+}
+
+// erase removes the last character of the selected value.
+func (s *setupScreen) erase() {
+
+	if s.selected == 0 {
+		return
+	}
+	i := s.selected - 1
+	if v := s.values[i]; v != "" {
+		s.values[i] = v[:len(v)-1]
+		s.err = ""
+	}
+	s.updatePreset()
+}
+
+// updatePreset shows the preset the values match after they were edited.
+func (s *setupScreen) updatePreset() {
+
+	s.preset = -1
+	if p, err := s.params(); err == nil {
+		s.preset = matchPreset(p)
+	}
+}
+
+// getField returns the value the pointer of a setup field points to.
+func getField(v any) int64 {
+
+	switch v := v.(type) {
+	case *int:
+		return int64(*v)
+	case *int64:
+		return *v
+	}
+	return 0
+}
+
+// setField sets the value the pointer of a setup field points to and
+// reports whether the value fits in it.
+func setField(v any, n int64) bool {
+
+	switch v := v.(type) {
+	case *int:
+		*v = int(n)
+		return int64(*v) == n
+	case *int64:
+		*v = n
+	}
+	return true
+}
+
+// repeating reports whether the key was just pressed or has been held long
+// enough to repeat.
+func repeating(key ebiten.Key) bool {
+
+	d := inpututil.KeyPressDuration(key)
+	return d == 1 || (d >= 30 && d%4 == 0)
+}
+
+// updateSetup handles the keys of the setup screen.
+func (g *Game) updateSetup() error {
+
+	s := g.setup
+	rows := len(setupFields) + 1
+	step := 1
+	if ebiten.IsKeyPressed(ebiten.KeyShift) {
+		step = 10
+	}
+
+	switch {
+	case repeating(ebiten.KeyUp):
+		s.selected = (s.selected + rows - 1) % rows
+	case repeating(ebiten.KeyDown), inpututil.IsKeyJustPressed(ebiten.KeyTab):
+		s.selected = (s.selected + 1) % rows
+	case repeating(ebiten.KeyLeft):
+		s.change(-step)
+	case repeating(ebiten.KeyRight):
+		s.change(step)
+	case repeating(ebiten.KeyBackspace):
+		s.erase()
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnter), inpututil.IsKeyJustPressed(ebiten.KeyNumpadEnter):
+		g.startSetup()
+		return nil
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape) && s.back:
+		g.setup = nil
+		return nil
+	case inpututil.IsKeyJustPressed(ebiten.KeyQ):
+		g.stopRecording()
+		os.Exit(0)
+	}
+
+	s.typeChars(ebiten.AppendInputChars(nil))
+	return nil
+}
+
+// startSetup starts a new world with the parameters of the setup screen.
+// The screen stays up and shows why if the world can't be started.
+func (g *Game) startSetup() {
+
+	p, err := g.setup.params()
+	if err == nil {
+		err = g.Init(p)
+	}
+	if err != nil {
+		g.setup.err = err.Error()
+		return
+	}
+	g.setup = nil
+	g.pause = false
+	g.ended = ""
+	g.status = ""
+}
+
+// DrawSetup draws the setup screen.
+func (g *Game) DrawSetup(screen *ebiten.Image) {
+
+	s := g.setup
+	screen.Fill(color.RGBA{120, 180, 255, 255})
+	box := image.Rect(56, 24, SetupWidth-56, SetupHeight-24)
+	drawNineSlice(screen, g.panelSprite, box, PanelBorder)
+
+	x, y := box.Min.X+2*PanelBorder+8, box.Min.Y+2*PanelBorder+16
+	face := basicfont.Face7x13
+	text.Draw(screen, "New world", face, x, y, color.White)
+	y += SetupRowH + 4
+
+	highlight := color.RGBA{255, 220, 80, 255}
+	for row := 0; row <= len(setupFields); row++ {
+		label, value := "Preset", "Custom"
+		if row == 0 && s.preset >= 0 {
+			value = presets[s.preset].name
+		}
+		if row == 0 {
+			value = "< " + value + " >"
+		} else {
+			label, value = setupFields[row-1].label, s.values[row-1]
+		}
+
+		clr := color.Color(color.White)
+		if row == s.selected {
+			clr = highlight
+			text.Draw(screen, ">", face, x-10, y, clr)
+			if row > 0 {
+				value += "_"
+			}
+		}
+		text.Draw(screen, label, face, x, y, clr)
+		text.Draw(screen, value, face, x+160, y, clr)
+		y += SetupRowH
+	}
+
+	if s.err != "" {
+		text.Draw(screen, s.err, face, x, y+4, color.RGBA{255, 110, 110, 255})
+	}
+
+	help := "UP/DOWN to select, LEFT/RIGHT to change (SHIFT by 10),\ndigits and BACKSPACE to edit.\nENTER to start, Q to quit."
+	if s.back {
+		help = "UP/DOWN to select, LEFT/RIGHT to change (SHIFT by 10),\ndigits and BACKSPACE to edit.\nENTER to start, ESC to go back, Q to quit."
+	}
+	text.Draw(screen, help, face, x, box.Max.Y-2*PanelBorder-36, color.White)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// speed is a rate the live world can run at.  Animated speeds update the
+// world every ticks ticks and turbo speeds update it chronons times every
+// tick without animating the creatures.
+type speed struct {
+	ticks    int // ticks per chronon when animated
+	chronons int // chronons per tick in turbo mode
+}
+
+// speeds are the rates of the live world from the slowest to the fastest.
+var speeds = []speed{
+	{ticks: 240}, {ticks: 120}, {ticks: 60}, {ticks: 30}, {ticks: 15}, {ticks: 8},
+	{chronons: 1}, {chronons: 4}, {chronons: 16}, {chronons: 64}, {chronons: 256}, {chronons: 1024},
+}
+
+const (
+	defaultSpeed = 2 // one chronon per second
+	turboSpeed   = 8 // speed T switches to
+)
+
+// speedKeys returns 1 if a key to go faster was pressed, -1 for slower and 0
+// otherwise.
+func speedKeys() int {
+
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyUp), inpututil.IsKeyJustPressed(ebiten.KeyEqual),
+		inpututil.IsKeyJustPressed(ebiten.KeyNumpadAdd):
+		return 1
+	case inpututil.IsKeyJustPressed(ebiten.KeyDown), inpututil.IsKeyJustPressed(ebiten.KeyMinus),
+		inpututil.IsKeyJustPressed(ebiten.KeyNumpadSubtract):
+		return -1
+	}
+	return 0
+}
+
+// setSpeed changes the rate of the live world to the index of speeds.
+func (g *Game) setSpeed(i int) {
+
+	g.speed = min(max(i, 0), len(speeds)-1)
+	if s := speeds[g.speed]; s.ticks > 0 {
+		g.tpsPerChronon = s.ticks
+	}
+	g.ctickCounter = 0
+}
+
+// toggleTurbo switches between turbo mode and the speed used before it.
+func (g *Game) toggleTurbo() {
+
+	if speeds[g.speed].chronons > 0 {
+		if speeds[g.lastSpeed].ticks == 0 {
+			g.lastSpeed = defaultSpeed
+		}
+		g.setSpeed(g.lastSpeed)
+		return
+	}
+	g.lastSpeed = g.speed
+	g.setSpeed(turboSpeed)
+}
+
+// runTurbo advances the world up to n chronons without animation.  It stops
+// early when the tick takes too long so that the game stays responsive.
+func (g *Game) runTurbo(n int) {
+
+	budget := time.Second * 3 / 4 / time.Duration(ebiten.TPS())
+	start := time.Now()
+	done := 0
+	for done < n && !g.pause && time.Since(start) < budget {
+		if _, _, live := g.advance(); live {
+			g.checkStop()
+		}
+		done++
+	}
+
+	g.turboDone = done
+	g.show(g.shownState())
+}
+
+// speedStatus describes the rate the world runs at.
+func (g *Game) speedStatus() string {
+
+	s := speeds[g.speed]
+	if s.chronons > 0 {
+		return fmt.Sprintf("turbo %d/tick (%.0f chronons/s)", s.chronons, float64(g.turboDone)*ebiten.ActualTPS())
+	}
+	return fmt.Sprintf("%g chronons/s", float64(ebiten.TPS())/float64(s.ticks))
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"time"
+
+	"lazyhacker.dev/wa-tor/internal/chart"
+	"lazyhacker.dev/wa-tor/internal/wator"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// trail is the latest tiles a creature was on, from the oldest to the one
+// it is on.
+type trail struct {
+	species int
+	tiles   []int
+}
+
+// trailMap follows the creatures through the changes of the chronons by the
+// tile they are on.  The trails are only kept while they are shown.
+type trailMap struct {
+	on     bool
+	length int            // Tiles a trail covers at most.
+	trails map[int]*trail // Trails by the tile of their creature.
+}
+
+// reset starts a trail for every creature of the state that covers at most
+// length tiles.
+func (t *trailMap) reset(state wator.WorldState, length int) {
+
+	t.length = max(length, 2)
+	t.trails = make(map[int]*trail)
+	if !t.on {
+		return
+	}
+	for i, s := range state {
+		if s != wator.NONE {
+			t.trails[i] = &trail{species: s, tiles: []int{i}}
+		}
+	}
+}
+
+// add follows the creatures through the changes of a chronon.  New born
+// creatures start a trail and the trails of the creatures that died end.
+func (t *trailMap) add(changes []wator.Delta) {
+
+	if !t.on {
+		return
+	}
+	for _, d := range changes {
+		switch d.Action {
+		case wator.BIRTH:
+			t.trails[d.From] = &trail{species: d.Object, tiles: []int{d.From}}
+		case wator.DEATH:
+			delete(t.trails, d.From)
+		case wator.ATE:
+			delete(t.trails, d.To)
+		case wator.MOVE_NORTH, wator.MOVE_SOUTH, wator.MOVE_EAST, wator.MOVE_WEST:
+			tr, ok := t.trails[d.From]
+			if !ok {
+				tr = &trail{species: d.Object, tiles: []int{d.From}}
+			}
+			delete(t.trails, d.From)
+			if len(tr.tiles) == t.length {
+				copy(tr.tiles, tr.tiles[1:])
+				tr.tiles = tr.tiles[:len(tr.tiles)-1]
+			}
+			tr.tiles = append(tr.tiles, d.To)
+			t.trails[d.To] = tr
+		}
+	}
+}
+
+// toggleTrails shows or hides the trails and returns a message saying so.
+func (g *Game) toggleTrails() string {
+
+	g.trails.on = !g.trails.on
+	g.trails.reset(g.shownState(), *trailLength)
+	if g.trails.on {
+		return fmt.Sprintf("Trails of the last %d tiles", g.trails.length)
+	}
+	return "Trails off"
+}
+
+// trailPath returns the columns and rows of the tiles of the trail in a
+// world of width by height tiles, unwrapped so that consecutive tiles are
+// next to each other.  The last tile is where the creature is, the others
+// may be past the edges of the world.
+func trailPath(tiles []int, width, height int) [][2]int {
+
+	path := make([][2]int, len(tiles))
+	last := tiles[len(tiles)-1]
+	path[len(path)-1] = [2]int{last % width, last / width}
+	for i := len(tiles) - 2; i >= 0; i-- {
+		dc := tiles[i]%width - tiles[i+1]%width
+		dr := tiles[i]/width - tiles[i+1]/width
+		// A step of more than half the world went around an edge.
+		switch {
+		case dc > width/2:
+			dc -= width
+		case dc < -width/2:
+			dc += width
+		}
+		switch {
+		case dr > height/2:
+			dr -= height
+		case dr < -height/2:
+			dr += height
+		}
+		path[i] = [2]int{path[i+1][0] + dc, path[i+1][1] + dr}
+	}
+	return path
+}
+
+// trailColor returns the colour of the species faded to alpha, as
+// premultiplied RGBA.
+func trailColor(species int, alpha float64) color.RGBA {
+
+	c := chart.FishColor
+	if species == wator.SHARK {
+		c = chart.SharkColor
+	}
+	fade := func(v uint8) uint8 { return uint8(float64(v) * alpha) }
+	return color.RGBA{fade(c.R), fade(c.G), fade(c.B), fade(c.A)}
+}
+
+// DrawTrails draws the trails of the creatures through the camera, fading
+// from the creature to the oldest tile.  A trail crossing an edge of the
+// world is drawn leaving one side and entering on the other.
+func (g *Game) DrawTrails(screen *ebiten.Image) {
+
+	progress := g.anim.progress(time.Now())
+	width := float32(max(1.5, TileSize*g.cam.zoom/8))
+	for pos, tr := range g.trails.trails {
+		if len(tr.tiles) < 2 {
+			continue
+		}
+		path := trailPath(tr.tiles, g.world.Width, g.world.Height)
+		points := make([][2]float64, len(path))
+		minX, minY := float64(path[0][0]*TileSize), float64(path[0][1]*TileSize)
+		maxX, maxY := minX, minY
+		for i, p := range path {
+			x, y := float64(p[0]*TileSize+TileSize/2), float64(p[1]*TileSize+TileSize/2)
+			if i == len(path)-1 {
+				// The trail ends at the creature as it is drawn moving.
+				dx, dy := moveOffset(g.anim.changes, pos, progress)
+				x, y = x+dx, y+dy
+			}
+			points[i] = [2]float64{x, y}
+			minX, minY = min(minX, x), min(minY, y)
+			maxX, maxY = max(maxX, x), max(maxY, y)
+		}
+
+		g.onScreen(minX, minY, maxX-minX+1, maxY-minY+1, func(sx, sy float64) {
+			for i := 1; i < len(points); i++ {
+				a, b := points[i-1], points[i]
+				clr := trailColor(tr.species, float64(i)/float64(len(points)-1))
+				vector.StrokeLine(screen,
+					float32(sx+(a[0]-minX)*g.cam.zoom), float32(sy+(a[1]-minY)*g.cam.zoom),
+					float32(sx+(b[0]-minX)*g.cam.zoom), float32(sy+(b[1]-minY)*g.cam.zoom),
+					width, clr, true)
+			}
+		})
+	}
+}